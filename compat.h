@@ -0,0 +1,101 @@
+/**
+ * File        : compat.h
+ * Copyright   : Copyright (c) 2015-2017 Mirror Labs, Inc. All rights reserved.
+ * License     : GPLv3
+ * Maintainer  : Enzo Haussecker <enzo@mirror.co>, Dominic Williams <dominic@string.technology>
+ * Stability   : Experimental
+ * Portability : Non-portable (requires Tox core at commit dcf2aaa or c-toxcore 0.2)
+ *
+ * This header hides the differences between the two versions of the Tox core
+ * API that these bindings support: the API at commit dcf2aaa, which is the
+ * default, and the API of c-toxcore 0.2, which is selected with the toxcore02
+ * build tag. The callback registration functions in callbacks.h make the same
+ * distinction.
+ */
+
+#ifndef COMPAT_H
+#define COMPAT_H
+
+#include <stdbool.h>
+#include <stdint.h>
+#include <stdlib.h>
+#include <tox/tox.h>
+#include "log.h"
+
+// Fail early if the header does not match the selected API, rather than with
+// an obscure error about the arguments of tox_iterate.
+#if defined(TOXCORE_02) && TOX_VERSION_MAJOR == 0 && TOX_VERSION_MINOR < 2
+#error "The toxcore02 build tag requires the c-toxcore 0.2 header."
+#endif
+#if !defined(TOXCORE_02) && TOX_VERSION_MAJOR == 0 && TOX_VERSION_MINOR >= 2
+#error "Found the c-toxcore 0.2 header. Build with the toxcore02 build tag."
+#endif
+
+// Check whether the library we are linked against implements the API of the
+// header we were compiled against.
+static inline bool version_is_compatible(void) {
+    return tox_version_is_compatible(TOX_VERSION_MAJOR, TOX_VERSION_MINOR, TOX_VERSION_PATCH);
+}
+
+#ifdef TOXCORE_02
+
+// In c-toxcore 0.2, the user data is passed to tox_iterate, which passes it on
+// to every callback invoked during the iteration.
+static inline void iterate(Tox *tox, uintptr_t t) {
+    tox_iterate(tox, (void *) t);
+}
+
+void callback_log(struct Tox *, TOX_LOG_LEVEL, const char *, uint32_t, const char *, const char *, void *);
+
+// Send the log records of the core to our hook. The user data is the handle
+// of the logger, since the core logs before the instance is registered.
+static inline void set_log_callback(struct Tox_Options *options, uintptr_t t) {
+    tox_options_set_log_callback(options, callback_log);
+    tox_options_set_log_user_data(options, (void *) t);
+}
+
+#else
+
+// At commit dcf2aaa, the user data is registered with each callback.
+static inline void iterate(Tox *tox, uintptr_t t) {
+    tox_iterate(tox);
+}
+
+// Commit dcf2aaa has no log callback, so the core does not log.
+static inline void set_log_callback(struct Tox_Options *options, uintptr_t t) {
+}
+
+// Commit dcf2aaa has no accessors for the startup options. This macro creates
+// the accessors that c-toxcore 0.2 provides, so that the bindings can use them
+// with both versions.
+#define GEN_OPTIONS_API(type, name) \
+static inline type tox_options_get_##name(const struct Tox_Options *options) { \
+    return options->name; \
+} \
+static inline void tox_options_set_##name(struct Tox_Options *options, type name) { \
+    options->name = name; \
+}
+
+GEN_OPTIONS_API(bool, ipv6_enabled)
+GEN_OPTIONS_API(bool, udp_enabled)
+GEN_OPTIONS_API(TOX_PROXY_TYPE, proxy_type)
+GEN_OPTIONS_API(const char *, proxy_host)
+GEN_OPTIONS_API(uint16_t, proxy_port)
+GEN_OPTIONS_API(uint16_t, start_port)
+GEN_OPTIONS_API(uint16_t, end_port)
+GEN_OPTIONS_API(uint16_t, tcp_port)
+GEN_OPTIONS_API(TOX_SAVEDATA_TYPE, savedata_type)
+GEN_OPTIONS_API(size_t, savedata_length)
+
+// The savedata setter takes the length along with the data.
+static inline const uint8_t *tox_options_get_savedata_data(const struct Tox_Options *options) {
+    return options->savedata_data;
+}
+static inline void tox_options_set_savedata_data(struct Tox_Options *options, const uint8_t *data, size_t length) {
+    options->savedata_data = data;
+    options->savedata_length = length;
+}
+
+#endif
+
+#endif
@@ -0,0 +1,302 @@
+//go:build toxcore02
+
+/**
+ * File        : conference.go
+ * Copyright   : Copyright (c) 2015-2017 Mirror Labs, Inc. All rights reserved.
+ * License     : GPLv3
+ * Maintainer  : Enzo Haussecker <enzo@mirror.co>, Dominic Williams <dominic@string.technology>
+ * Stability   : Experimental
+ * Portability : Non-portable (requires c-toxcore 0.2)
+ *
+ * This module binds the conference API of c-toxcore 0.2. At commit dcf2aaa,
+ * group chats belong to the old API in tox_old.h, which these bindings do not
+ * wrap, so conferences are only available with the toxcore02 build tag.
+ */
+
+package tox
+
+//#include "callbacks.h"
+import "C"
+
+////////////////////////////////////////////////////////////////////////////////
+////////////////////////////// CALLBACK FUNCTIONS //////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// This function registers a function that executes when a friend invites the
+// client to a conference.
+func (tox *Tox) SetOnConferenceInvite(callback OnConferenceInvite) {
+    tox.exec(nil, func() {
+        tox.onConferenceInvite = callback
+        C.register_conference_invite(tox.handle, C.uintptr_t(tox.id))
+    })
+}
+
+// This function registers a function that executes when receiving a chat
+// message from a conference peer.
+func (tox *Tox) SetOnConferenceMessage(callback OnConferenceMessage) {
+    tox.exec(nil, func() {
+        tox.onConferenceMessage = callback
+        C.register_conference_message(tox.handle, C.uintptr_t(tox.id))
+    })
+}
+
+// This function registers a function that executes when a conference peer
+// changes the conference title.
+func (tox *Tox) SetOnConferenceTitle(callback OnConferenceTitle) {
+    tox.exec(nil, func() {
+        tox.onConferenceTitle = callback
+        C.register_conference_title(tox.handle, C.uintptr_t(tox.id))
+    })
+}
+
+// This function registers a function that executes when the peer list of a
+// conference changes.
+func (tox *Tox) SetOnConferencePeerListChanged(callback OnConferencePeerListChanged) {
+    tox.exec(nil, func() {
+        tox.onConferencePeerListChanged = callback
+        C.register_conference_peer_list_changed(tox.handle, C.uintptr_t(tox.id))
+    })
+}
+
+////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////// CONFERENCES //////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Create a new conference. The client is the only peer of the conference until
+// friends are invited.
+func (tox *Tox) ConferenceNew() (conferenceNumber uint32, throw error) {
+    tox.exec(&throw, func() {
+        var c_error C.TOX_ERR_CONFERENCE_NEW
+        var c_conference_number = C.tox_conference_new(tox.handle, &c_error)
+        if (c_error != C.TOX_ERR_CONFERENCE_NEW_OK) {
+            throw = errorConferenceNew("tox_conference_new", c_error)
+        } else {
+            conferenceNumber = uint32(c_conference_number)
+        }
+    })
+    return
+}
+
+// Leave a conference. This will release all resources associated with it.
+func (tox *Tox) ConferenceDelete(conferenceNumber uint32) (throw error) {
+    tox.exec(&throw, func() {
+        var c_conference_number = C.uint32_t(conferenceNumber)
+        var c_error C.TOX_ERR_CONFERENCE_DELETE
+        C.tox_conference_delete(tox.handle, c_conference_number, &c_error)
+        if (c_error != C.TOX_ERR_CONFERENCE_DELETE_OK) {
+            throw = errorConferenceDelete("tox_conference_delete", c_error)
+        }
+    })
+    return
+}
+
+// Get the number of peers in a conference.
+func (tox *Tox) ConferencePeerCount(conferenceNumber uint32) (count uint32, throw error) {
+    tox.exec(&throw, func() {
+        var c_conference_number = C.uint32_t(conferenceNumber)
+        var c_error C.TOX_ERR_CONFERENCE_PEER_QUERY
+        var c_count = C.tox_conference_peer_count(tox.handle, c_conference_number, &c_error)
+        if (c_error != C.TOX_ERR_CONFERENCE_PEER_QUERY_OK) {
+            throw = errorConferencePeerQuery("tox_conference_peer_count", c_error)
+        } else {
+            count = uint32(c_count)
+        }
+    })
+    return
+}
+
+// Get the name of a conference peer.
+func (tox *Tox) ConferencePeerGetName(conferenceNumber uint32, peerNumber uint32) (name []byte, throw error) {
+    tox.exec(&throw, func() {
+        var c_conference_number = C.uint32_t(conferenceNumber)
+        var c_peer_number = C.uint32_t(peerNumber)
+        var c_error C.TOX_ERR_CONFERENCE_PEER_QUERY
+        var c_length = C.tox_conference_peer_get_name_size(tox.handle, c_conference_number, c_peer_number, &c_error)
+        if (c_error == C.TOX_ERR_CONFERENCE_PEER_QUERY_OK) {
+            var c_name *C.uint8_t
+            name = make([]byte, c_length)
+            if (c_length > 0) {
+                c_name = (*C.uint8_t)(&name[0])
+            }
+            C.tox_conference_peer_get_name(tox.handle, c_conference_number, c_peer_number, c_name, &c_error)
+        }
+        if (c_error != C.TOX_ERR_CONFERENCE_PEER_QUERY_OK) {
+            name = nil
+            throw = errorConferencePeerQuery("tox_conference_peer_get_name", c_error)
+        }
+    })
+    return
+}
+
+// Get the public key of a conference peer.
+func (tox *Tox) ConferencePeerGetPublicKey(conferenceNumber uint32, peerNumber uint32) (publicKey ToxPublicKey, throw error) {
+    tox.exec(&throw, func() {
+        var c_conference_number = C.uint32_t(conferenceNumber)
+        var c_peer_number = C.uint32_t(peerNumber)
+        var c_public_key = (*C.uint8_t)(&publicKey[0])
+        var c_error C.TOX_ERR_CONFERENCE_PEER_QUERY
+        C.tox_conference_peer_get_public_key(tox.handle, c_conference_number, c_peer_number, c_public_key, &c_error)
+        if (c_error != C.TOX_ERR_CONFERENCE_PEER_QUERY_OK) {
+            throw = errorConferencePeerQuery("tox_conference_peer_get_public_key", c_error)
+        }
+    })
+    return
+}
+
+// Invite a friend to a conference.
+func (tox *Tox) ConferenceInvite(friendNumber uint32, conferenceNumber uint32) (throw error) {
+    defer tox.wakeup()
+    tox.exec(&throw, func() {
+        var c_friend_number = C.uint32_t(friendNumber)
+        var c_conference_number = C.uint32_t(conferenceNumber)
+        var c_error C.TOX_ERR_CONFERENCE_INVITE
+        C.tox_conference_invite(tox.handle, c_friend_number, c_conference_number, &c_error)
+        if (c_error != C.TOX_ERR_CONFERENCE_INVITE_OK) {
+            throw = errorConferenceInvite("tox_conference_invite", c_error)
+        }
+    })
+    return
+}
+
+// Join a conference that the client has been invited to. The cookie is the one
+// received with the invitation.
+func (tox *Tox) ConferenceJoin(friendNumber uint32, cookie []byte) (conferenceNumber uint32, throw error) {
+    tox.exec(&throw, func() {
+        var c_friend_number = C.uint32_t(friendNumber)
+        var c_length = C.size_t(len(cookie))
+        var c_cookie *C.uint8_t
+        var c_error C.TOX_ERR_CONFERENCE_JOIN
+        if (c_length > 0) {
+            c_cookie = (*C.uint8_t)(&cookie[0])
+        }
+        var c_conference_number = C.tox_conference_join(tox.handle, c_friend_number, c_cookie, c_length, &c_error)
+        if (c_error != C.TOX_ERR_CONFERENCE_JOIN_OK) {
+            throw = errorConferenceJoin("tox_conference_join", c_error)
+        } else {
+            conferenceNumber = uint32(c_conference_number)
+        }
+    })
+    return
+}
+
+// Send a chat message to a conference.
+func (tox *Tox) ConferenceSendMessage(conferenceNumber uint32, messageType ToxMessageType, message []byte) (throw error) {
+    defer tox.wakeup()
+    tox.exec(&throw, func() {
+        var c_conference_number = C.uint32_t(conferenceNumber)
+        var c_message_type C.TOX_MESSAGE_TYPE
+        var c_length = C.size_t(len(message))
+        var c_message *C.uint8_t
+        var c_error C.TOX_ERR_CONFERENCE_SEND_MESSAGE
+        switch messageType {
+            case ToxMessageTypeAction:
+                c_message_type = C.TOX_MESSAGE_TYPE_ACTION
+            default:
+                c_message_type = C.TOX_MESSAGE_TYPE_NORMAL
+        }
+        if (c_length > 0) {
+            c_message = (*C.uint8_t)(&message[0])
+        }
+        C.tox_conference_send_message(tox.handle, c_conference_number, c_message_type, c_message, c_length, &c_error)
+        if (c_error != C.TOX_ERR_CONFERENCE_SEND_MESSAGE_OK) {
+            throw = errorConferenceSendMessage("tox_conference_send_message", c_error)
+        }
+    })
+    return
+}
+
+// Get the title of a conference.
+func (tox *Tox) ConferenceGetTitle(conferenceNumber uint32) (title []byte, throw error) {
+    tox.exec(&throw, func() {
+        var c_conference_number = C.uint32_t(conferenceNumber)
+        var c_error C.TOX_ERR_CONFERENCE_TITLE
+        var c_length = C.tox_conference_get_title_size(tox.handle, c_conference_number, &c_error)
+        if (c_error == C.TOX_ERR_CONFERENCE_TITLE_OK) {
+            var c_title *C.uint8_t
+            title = make([]byte, c_length)
+            if (c_length > 0) {
+                c_title = (*C.uint8_t)(&title[0])
+            }
+            C.tox_conference_get_title(tox.handle, c_conference_number, c_title, &c_error)
+        }
+        if (c_error != C.TOX_ERR_CONFERENCE_TITLE_OK) {
+            title = nil
+            throw = errorConferenceTitle("tox_conference_get_title", c_error)
+        }
+    })
+    return
+}
+
+// Set the title of a conference.
+func (tox *Tox) ConferenceSetTitle(conferenceNumber uint32, title []byte) (throw error) {
+    tox.exec(&throw, func() {
+        var c_conference_number = C.uint32_t(conferenceNumber)
+        var c_length = C.size_t(len(title))
+        var c_title *C.uint8_t
+        var c_error C.TOX_ERR_CONFERENCE_TITLE
+        if (c_length > 0) {
+            c_title = (*C.uint8_t)(&title[0])
+        }
+        C.tox_conference_set_title(tox.handle, c_conference_number, c_title, c_length, &c_error)
+        if (c_error != C.TOX_ERR_CONFERENCE_TITLE_OK) {
+            throw = errorConferenceTitle("tox_conference_set_title", c_error)
+        }
+    })
+    return
+}
+
+////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////// SUBSCRIPTIONS ////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Subscribe a function that executes when receiving a conference invitation
+// from a friend. This returns a function that cancels the subscription.
+func (tox *Tox) OnConferenceInvite(callback OnConferenceInvite) (unsubscribe func()) {
+    if (callback == nil) {
+        return func() {}
+    }
+    unsubscribe = tox.subscriptions[subscribeConferenceInvite].add(callback)
+    tox.exec(nil, func() {
+        C.register_conference_invite(tox.handle, C.uintptr_t(tox.id))
+    })
+    return
+}
+
+// Subscribe a function that executes when receiving a message in a conference.
+// This returns a function that cancels the subscription.
+func (tox *Tox) OnConferenceMessage(callback OnConferenceMessage) (unsubscribe func()) {
+    if (callback == nil) {
+        return func() {}
+    }
+    unsubscribe = tox.subscriptions[subscribeConferenceMessage].add(callback)
+    tox.exec(nil, func() {
+        C.register_conference_message(tox.handle, C.uintptr_t(tox.id))
+    })
+    return
+}
+
+// Subscribe a function that executes when the title of a conference changes.
+// This returns a function that cancels the subscription.
+func (tox *Tox) OnConferenceTitle(callback OnConferenceTitle) (unsubscribe func()) {
+    if (callback == nil) {
+        return func() {}
+    }
+    unsubscribe = tox.subscriptions[subscribeConferenceTitle].add(callback)
+    tox.exec(nil, func() {
+        C.register_conference_title(tox.handle, C.uintptr_t(tox.id))
+    })
+    return
+}
+
+// Subscribe a function that executes when the peer list of a conference
+// changes. This returns a function that cancels the subscription.
+func (tox *Tox) OnConferencePeerListChanged(callback OnConferencePeerListChanged) (unsubscribe func()) {
+    if (callback == nil) {
+        return func() {}
+    }
+    unsubscribe = tox.subscriptions[subscribeConferencePeerListChanged].add(callback)
+    tox.exec(nil, func() {
+        C.register_conference_peer_list_changed(tox.handle, C.uintptr_t(tox.id))
+    })
+    return
+}
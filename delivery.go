@@ -0,0 +1,311 @@
+/**
+ * File        : delivery.go
+ * Copyright   : Copyright (c) 2015-2017 Mirror Labs, Inc. All rights reserved.
+ * License     : GPLv3
+ * Maintainer  : Enzo Haussecker <enzo@mirror.co>, Dominic Williams <dominic@string.technology>
+ * Stability   : Experimental
+ * Portability : Non-portable (requires Tox core at commit dcf2aaa)
+ *
+ * This module tracks the delivery of chat messages. A message is pending until
+ * the friend sends a read receipt for it, at which point it is delivered. If
+ * the connection to the friend is lost first, the core discards the message,
+ * so it is considered failed.
+ */
+
+package tox
+
+//#include "callbacks.h"
+import "C"
+import "context"
+import "errors"
+import "sync"
+import "time"
+
+////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////// STRUCT TYPES /////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// This type tracks the delivery state of chat messages sent by a Tox instance.
+// A message is sent without holding the tracker lock, since the handlers of
+// the instance take the lock. A read receipt or a lost connection that arrives
+// while a message to the friend is being sent is recorded, and applied once
+// the message is tracked.
+type DeliveryTracker struct {
+
+    send     func(friendNumber uint32, messageType ToxMessageType, message []byte) (uint32, error)
+    lock     sync.Mutex
+    messages map[deliveryKey]*delivery
+    changed  chan struct{}
+    sending  map[uint32]int
+    receipts map[deliveryKey]bool
+    losses   map[uint32]uint64
+
+}
+
+// This type identifies a chat message within a Tox instance.
+type deliveryKey struct {
+
+    friendNumber uint32
+    messageId    uint32
+
+}
+
+// This type holds the delivery state of a chat message. The done channel is
+// closed once the state is final.
+type delivery struct {
+
+    state DeliveryState
+    done  chan struct{}
+
+}
+
+////////////////////////////////////////////////////////////////////////////////
+/////////////////////////////// ENUMERATED TYPES ///////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// The interval between two attempts to send a chat message while the send
+// queue of the core is full.
+const sendRetryInterval = 100 * time.Millisecond
+
+// This type represents the delivery state of a chat message.
+type DeliveryState int
+
+// The set of possible delivery states. A message is pending until a read
+// receipt arrives or the connection to the friend is lost.
+const (
+
+    DeliveryPending DeliveryState = iota
+    DeliveryDelivered
+    DeliveryFailed
+
+)
+
+////////////////////////////////////////////////////////////////////////////////
+////////////////////////////// DELIVERY TRACKING ///////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Get the delivery tracker of a Tox instance. The tracker is created on first
+// use, and only tracks messages sent through it.
+func (tox *Tox) Deliveries() *DeliveryTracker {
+    tox.deliveriesOnce.Do(func() {
+        tox.deliveriesLock.Lock()
+        tox.deliveries = newDeliveryTracker(tox.view.FriendSendMessage)
+        tox.deliveriesLock.Unlock()
+        tox.exec(nil, func() {
+            C.register_friend_read_receipt(tox.handle, C.uintptr_t(tox.id))
+            C.register_friend_connection_status(tox.handle, C.uintptr_t(tox.id))
+        })
+    })
+    tox.deliveriesLock.Lock()
+    defer tox.deliveriesLock.Unlock()
+    return tox.deliveries
+}
+
+// Create a delivery tracker that sends chat messages with the given function.
+func newDeliveryTracker(send func(uint32, ToxMessageType, []byte) (uint32, error)) *DeliveryTracker {
+    return &DeliveryTracker {
+        send: send,
+        messages: make(map[deliveryKey]*delivery),
+        changed: make(chan struct{}),
+        sending: make(map[uint32]int),
+        receipts: make(map[deliveryKey]bool),
+        losses: make(map[uint32]uint64),
+    }
+}
+
+// Send a chat message to an online friend and track its delivery.
+func (tracker *DeliveryTracker) Send(friendNumber uint32, messageType ToxMessageType, message []byte) (messageId uint32, throw error) {
+    tracker.lock.Lock()
+    tracker.sending[friendNumber]++
+    var losses = tracker.losses[friendNumber]
+    tracker.lock.Unlock()
+    messageId, throw = tracker.send(friendNumber, messageType, message)
+    tracker.lock.Lock()
+    defer tracker.lock.Unlock()
+    var key = deliveryKey{friendNumber, messageId}
+    var received = tracker.receipts[key]
+    tracker.sending[friendNumber]--
+    if (tracker.sending[friendNumber] == 0) {
+        delete(tracker.sending, friendNumber)
+        for receipt := range tracker.receipts {
+            if (receipt.friendNumber == friendNumber) {
+                delete(tracker.receipts, receipt)
+            }
+        }
+    }
+    if throw != nil {
+        return
+    }
+    var entry = &delivery {
+        state: DeliveryPending,
+        done: make(chan struct{}),
+    }
+    tracker.messages[key] = entry
+    if received {
+        delete(tracker.receipts, key)
+        entry.settle(DeliveryDelivered)
+    } else if (tracker.losses[friendNumber] != losses) {
+        entry.settle(DeliveryFailed)
+    }
+    return
+}
+
+// Get the delivery state of a chat message. This returns false if the message
+// is not tracked.
+func (tracker *DeliveryTracker) State(friendNumber uint32, messageId uint32) (state DeliveryState, ok bool) {
+    tracker.lock.Lock()
+    defer tracker.lock.Unlock()
+    message, ok := tracker.messages[deliveryKey{friendNumber, messageId}]
+    if ok {
+        state = message.state
+    }
+    return
+}
+
+// Wait until a chat message is delivered or has failed, or the context is
+// done. The message is no longer tracked once a final state is returned.
+func (tracker *DeliveryTracker) Wait(ctx context.Context, friendNumber uint32, messageId uint32) (state DeliveryState, throw error) {
+    var key = deliveryKey{friendNumber, messageId}
+    tracker.lock.Lock()
+    message, ok := tracker.messages[key]
+    tracker.lock.Unlock()
+    if (!ok) {
+        return DeliveryFailed, ToxErrDeliveryNotTracked
+    }
+    select {
+        case <-message.done:
+            tracker.lock.Lock()
+            state = message.state
+            delete(tracker.messages, key)
+            tracker.lock.Unlock()
+        case <-ctx.Done():
+            state = DeliveryPending
+            throw = ctx.Err()
+    }
+    return
+}
+
+// Wait until a chat message is delivered or has failed, or the timeout
+// elapses.
+func (tracker *DeliveryTracker) WaitTimeout(friendNumber uint32, messageId uint32, timeout time.Duration) (state DeliveryState, throw error) {
+    ctx, cancel := context.WithTimeout(context.Background(), timeout)
+    defer cancel()
+    return tracker.Wait(ctx, friendNumber, messageId)
+}
+
+// Stop tracking a chat message.
+func (tracker *DeliveryTracker) Forget(friendNumber uint32, messageId uint32) {
+    tracker.lock.Lock()
+    delete(tracker.messages, deliveryKey{friendNumber, messageId})
+    tracker.lock.Unlock()
+}
+
+// Send a chat message to a friend and wait until it is delivered. While the
+// friend is offline or the send queue of the core is full, the message is held
+// back and sent once possible. If the connection to the friend is lost before
+// a read receipt arrives, then the message is sent again, so it may be
+// received more than once. This returns the identifier of the delivered
+// message, or the context error if the context is done first. The instance
+// must be processed by another goroutine for this to make progress.
+func (tox *Tox) SendMessageContext(ctx context.Context, friendNumber uint32, messageType ToxMessageType, message []byte) (messageId uint32, throw error) {
+    var tracker = tox.Deliveries()
+    for {
+        var changed = tracker.connectionChanged()
+        messageId, throw = tracker.Send(friendNumber, messageType, message)
+        switch {
+            case throw == nil:
+                var state DeliveryState
+                state, throw = tracker.Wait(ctx, friendNumber, messageId)
+                if throw != nil {
+                    tracker.Forget(friendNumber, messageId)
+                    return
+                }
+                if (state == DeliveryDelivered) {
+                    return
+                }
+            case errors.Is(throw, ToxErrFriendSendMessageFriendNotConnected):
+                select {
+                    case <-changed:
+                    case <-ctx.Done():
+                        return 0, ctx.Err()
+                }
+            case errors.Is(throw, ToxErrFriendSendMessageSendQ):
+                var timer = time.NewTimer(sendRetryInterval)
+                select {
+                    case <-timer.C:
+                    case <-ctx.Done():
+                        timer.Stop()
+                        return 0, ctx.Err()
+                }
+            default:
+                return
+        }
+    }
+}
+
+// Get a channel that is closed the next time the connection status of any
+// friend changes.
+func (tracker *DeliveryTracker) connectionChanged() <-chan struct{} {
+    tracker.lock.Lock()
+    defer tracker.lock.Unlock()
+    return tracker.changed
+}
+
+////////////////////////////////////////////////////////////////////////////////
+/////////////////////////////// EVENT PROCESSING ///////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Set the final delivery state of a pending chat message. The caller must hold
+// the tracker lock.
+func (message *delivery) settle(state DeliveryState) {
+    if (message.state == DeliveryPending) {
+        message.state = state
+        close(message.done)
+    }
+}
+
+// Handle a read receipt from a friend.
+func (tox *Tox) handleDeliveryReadReceipt(friendNumber uint32, messageId uint32) {
+    tox.deliveriesLock.Lock()
+    var tracker = tox.deliveries
+    tox.deliveriesLock.Unlock()
+    if (tracker == nil) {
+        return
+    }
+    tracker.lock.Lock()
+    defer tracker.lock.Unlock()
+    var key = deliveryKey{friendNumber, messageId}
+    message, ok := tracker.messages[key]
+    if ok {
+        message.settle(DeliveryDelivered)
+    } else if (tracker.sending[friendNumber] > 0) {
+        tracker.receipts[key] = true
+    }
+}
+
+// Handle a change in the connection status of a friend. Goroutines waiting for
+// a friend to come online are woken up. The core discards unconfirmed messages
+// when the connection is lost, so all pending messages to the friend fail.
+func (tox *Tox) handleDeliveryConnectionStatus(friendNumber uint32, connectionStatus ToxConnectionStatus) {
+    tox.deliveriesLock.Lock()
+    var tracker = tox.deliveries
+    tox.deliveriesLock.Unlock()
+    if (tracker == nil) {
+        return
+    }
+    tracker.lock.Lock()
+    defer tracker.lock.Unlock()
+    close(tracker.changed)
+    tracker.changed = make(chan struct{})
+    if (connectionStatus != ToxConnectionNone) {
+        return
+    }
+    if (tracker.sending[friendNumber] > 0) {
+        tracker.losses[friendNumber]++
+    }
+    for key, message := range tracker.messages {
+        if (key.friendNumber == friendNumber) {
+            message.settle(DeliveryFailed)
+        }
+    }
+}
@@ -0,0 +1,311 @@
+/**
+ * File        : dispatch.go
+ * Copyright   : Copyright (c) 2015-2017 Mirror Labs, Inc. All rights reserved.
+ * License     : GPLv3
+ * Maintainer  : Enzo Haussecker <enzo@mirror.co>, Dominic Williams <dominic@string.technology>
+ * Stability   : Experimental
+ * Portability : Non-portable (requires Tox core at commit dcf2aaa)
+ *
+ * Tox instances handle events using callback functions. The generated hooks in
+ * callbacks.go copy the event data and pass it to the dispatch methods in this
+ * module, which queue a handler that Process runs once the core is done. Each
+ * handler first runs the internal handlers, then the callback registered
+ * through the setter, and then the subscribers to the event in the order in
+ * which they subscribed.
+ */
+
+package tox
+
+////////////////////////////////////////////////////////////////////////////////
+/////////////////////////////////// DISPATCH ///////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Queue a handler to run once the core is done processing. This must only be
+// called while dispatching an event from a hook, while the lock of the
+// instance is held.
+func (tox *Tox) enqueue(handler func()) {
+    tox.pending = append(tox.pending, handler)
+}
+
+// Dispatch a self connection status event.
+func (tox *Tox) dispatchSelfConnectionStatus(connectionStatus ToxConnectionStatus) {
+    var callback = tox.onSelfConnectionStatus
+    tox.enqueue(func() {
+        tox.handleRelaysConnectionStatus(connectionStatus)
+        tox.publish(SelfConnectionStatusEvent{connectionStatus})
+        if (callback != nil) {
+            callback(tox, connectionStatus)
+        }
+        for _, subscriber := range tox.subscriptions[subscribeSelfConnectionStatus].snapshot() {
+            subscriber.callback.(OnSelfConnectionStatus)(tox, connectionStatus)
+        }
+    })
+}
+
+// Dispatch a friend name event.
+func (tox *Tox) dispatchFriendName(friendNumber uint32, name []byte) {
+    var callback = tox.onFriendName
+    tox.enqueue(func() {
+        tox.publish(FriendNameEvent{friendNumber, name})
+        if (callback != nil) {
+            callback(tox, friendNumber, name)
+        }
+        for _, subscriber := range tox.subscriptions[subscribeFriendName].snapshot() {
+            subscriber.callback.(OnFriendName)(tox, friendNumber, name)
+        }
+    })
+}
+
+// Dispatch a friend request event.
+func (tox *Tox) dispatchFriendRequest(publicKey ToxPublicKey, message []byte) {
+    var callback = tox.onFriendRequest
+    tox.enqueue(func() {
+        tox.publish(FriendRequestEvent{publicKey, message})
+        if (callback != nil) {
+            callback(tox, publicKey, message)
+        }
+        for _, subscriber := range tox.subscriptions[subscribeFriendRequest].snapshot() {
+            subscriber.callback.(OnFriendRequest)(tox, publicKey, message)
+        }
+    })
+}
+
+// Dispatch a friend status message event.
+func (tox *Tox) dispatchFriendStatusMessage(friendNumber uint32, message []byte) {
+    var callback = tox.onFriendStatusMessage
+    tox.enqueue(func() {
+        tox.publish(FriendStatusMessageEvent{friendNumber, message})
+        if (callback != nil) {
+            callback(tox, friendNumber, message)
+        }
+        for _, subscriber := range tox.subscriptions[subscribeFriendStatusMessage].snapshot() {
+            subscriber.callback.(OnFriendStatusMessage)(tox, friendNumber, message)
+        }
+    })
+}
+
+// Dispatch a friend status event.
+func (tox *Tox) dispatchFriendStatus(friendNumber uint32, userStatus ToxUserStatus) {
+    var callback = tox.onFriendStatus
+    tox.enqueue(func() {
+        tox.publish(FriendStatusEvent{friendNumber, userStatus})
+        if (callback != nil) {
+            callback(tox, friendNumber, userStatus)
+        }
+        for _, subscriber := range tox.subscriptions[subscribeFriendStatus].snapshot() {
+            subscriber.callback.(OnFriendStatus)(tox, friendNumber, userStatus)
+        }
+    })
+}
+
+// Dispatch a friend connection status event.
+func (tox *Tox) dispatchFriendConnectionStatus(friendNumber uint32, connectionStatus ToxConnectionStatus) {
+    var callback = tox.onFriendConnectionStatus
+    tox.enqueue(func() {
+        tox.handleTransfersConnectionStatus(friendNumber, connectionStatus)
+        tox.handleDeliveryConnectionStatus(friendNumber, connectionStatus)
+        tox.handleOutboxConnectionStatus(friendNumber, connectionStatus)
+        tox.publish(FriendConnectionStatusEvent{friendNumber, connectionStatus})
+        if (callback != nil) {
+            callback(tox, friendNumber, connectionStatus)
+        }
+        for _, subscriber := range tox.subscriptions[subscribeFriendConnectionStatus].snapshot() {
+            subscriber.callback.(OnFriendConnectionStatus)(tox, friendNumber, connectionStatus)
+        }
+    })
+}
+
+// Dispatch a friend typing event.
+func (tox *Tox) dispatchFriendTyping(friendNumber uint32, isTyping bool) {
+    var callback = tox.onFriendTyping
+    tox.enqueue(func() {
+        if (callback != nil) {
+            callback(tox, friendNumber, isTyping)
+        }
+        for _, subscriber := range tox.subscriptions[subscribeFriendTyping].snapshot() {
+            subscriber.callback.(OnFriendTyping)(tox, friendNumber, isTyping)
+        }
+    })
+}
+
+// Dispatch a friend message event.
+func (tox *Tox) dispatchFriendMessage(friendNumber uint32, messageType ToxMessageType, message []byte) {
+    var callback = tox.onFriendMessage
+    tox.enqueue(func() {
+        tox.publish(FriendMessageEvent{friendNumber, messageType, message})
+        if (callback != nil) {
+            callback(tox, friendNumber, messageType, message)
+        }
+        for _, subscriber := range tox.subscriptions[subscribeFriendMessage].snapshot() {
+            subscriber.callback.(OnFriendMessage)(tox, friendNumber, messageType, message)
+        }
+    })
+}
+
+// Dispatch a friend read receipt event.
+func (tox *Tox) dispatchFriendReadReceipt(friendNumber uint32, messageId uint32) {
+    var callback = tox.onFriendReadReceipt
+    tox.enqueue(func() {
+        tox.handleDeliveryReadReceipt(friendNumber, messageId)
+        tox.handleOutboxReadReceipt(friendNumber, messageId)
+        if (callback != nil) {
+            callback(tox, friendNumber, messageId)
+        }
+        for _, subscriber := range tox.subscriptions[subscribeFriendReadReceipt].snapshot() {
+            subscriber.callback.(OnFriendReadReceipt)(tox, friendNumber, messageId)
+        }
+    })
+}
+
+// Dispatch a friend lossy packet event.
+func (tox *Tox) dispatchFriendLossyPacket(friendNumber uint32, data []byte) {
+    var callback = tox.onFriendLossyPacket
+    tox.enqueue(func() {
+        if (callback != nil) {
+            callback(tox, friendNumber, data)
+        }
+        for _, subscriber := range tox.subscriptions[subscribeFriendLossyPacket].snapshot() {
+            subscriber.callback.(OnFriendLossyPacket)(tox, friendNumber, data)
+        }
+    })
+}
+
+// Dispatch a friend lossless packet event.
+func (tox *Tox) dispatchFriendLosslessPacket(friendNumber uint32, data []byte) {
+    var callback = tox.onFriendLosslessPacket
+    tox.enqueue(func() {
+        tox.publish(FriendLosslessPacketEvent{friendNumber, data})
+        if (callback != nil) {
+            callback(tox, friendNumber, data)
+        }
+        for _, subscriber := range tox.subscriptions[subscribeFriendLosslessPacket].snapshot() {
+            subscriber.callback.(OnFriendLosslessPacket)(tox, friendNumber, data)
+        }
+    })
+}
+
+// Dispatch a file recv control event.
+func (tox *Tox) dispatchFileRecvControl(friendNumber uint32, fileNumber uint32, control ToxFileControl) {
+    var callback = tox.onFileRecvControl
+    var handler = func() {
+        if (tox.handleFileRecvControl(friendNumber, fileNumber, control)) {
+            return
+        }
+        if (callback != nil) {
+            callback(tox, friendNumber, fileNumber, control)
+        }
+        for _, subscriber := range tox.subscriptions[subscribeFileRecvControl].snapshot() {
+            subscriber.callback.(OnFileRecvControl)(tox, friendNumber, fileNumber, control)
+        }
+    }
+    tox.enqueue(func() {
+        if (!tox.holdTransferEvent(friendNumber, fileNumber, handler)) {
+            handler()
+        }
+    })
+}
+
+// Dispatch a file chunk request event.
+func (tox *Tox) dispatchFileChunkRequest(friendNumber uint32, fileNumber uint32, position uint64, length int) {
+    var callback = tox.onFileChunkRequest
+    var handler = func() {
+        if (tox.handleFileChunkRequest(friendNumber, fileNumber, position, length)) {
+            return
+        }
+        if (callback != nil) {
+            callback(tox, friendNumber, fileNumber, position, length)
+        }
+        for _, subscriber := range tox.subscriptions[subscribeFileChunkRequest].snapshot() {
+            subscriber.callback.(OnFileChunkRequest)(tox, friendNumber, fileNumber, position, length)
+        }
+    }
+    tox.enqueue(func() {
+        if (!tox.holdTransferEvent(friendNumber, fileNumber, handler)) {
+            handler()
+        }
+    })
+}
+
+// Dispatch a file recv event.
+func (tox *Tox) dispatchFileRecv(friendNumber uint32, fileNumber uint32, kind ToxFileKind, fileSize uint64, filename []byte) {
+    var callback = tox.onFileRecv
+    tox.enqueue(func() {
+        if (tox.handleFileRecv(friendNumber, fileNumber, kind, fileSize, filename)) {
+            return
+        }
+        if (callback != nil) {
+            callback(tox, friendNumber, fileNumber, kind, fileSize, filename)
+        }
+        for _, subscriber := range tox.subscriptions[subscribeFileRecv].snapshot() {
+            subscriber.callback.(OnFileRecv)(tox, friendNumber, fileNumber, kind, fileSize, filename)
+        }
+    })
+}
+
+// Dispatch a file recv chunk event.
+func (tox *Tox) dispatchFileRecvChunk(friendNumber uint32, fileNumber uint32, position uint64, data []byte) {
+    var callback = tox.onFileRecvChunk
+    tox.enqueue(func() {
+        if (tox.handleFileRecvChunk(friendNumber, fileNumber, position, data)) {
+            return
+        }
+        if (callback != nil) {
+            callback(tox, friendNumber, fileNumber, position, data)
+        }
+        for _, subscriber := range tox.subscriptions[subscribeFileRecvChunk].snapshot() {
+            subscriber.callback.(OnFileRecvChunk)(tox, friendNumber, fileNumber, position, data)
+        }
+    })
+}
+
+// Dispatch a conference invite event.
+func (tox *Tox) dispatchConferenceInvite(friendNumber uint32, conferenceType ToxConferenceType, cookie []byte) {
+    var callback = tox.onConferenceInvite
+    tox.enqueue(func() {
+        if (callback != nil) {
+            callback(tox, friendNumber, conferenceType, cookie)
+        }
+        for _, subscriber := range tox.subscriptions[subscribeConferenceInvite].snapshot() {
+            subscriber.callback.(OnConferenceInvite)(tox, friendNumber, conferenceType, cookie)
+        }
+    })
+}
+
+// Dispatch a conference message event.
+func (tox *Tox) dispatchConferenceMessage(conferenceNumber uint32, peerNumber uint32, messageType ToxMessageType, message []byte) {
+    var callback = tox.onConferenceMessage
+    tox.enqueue(func() {
+        if (callback != nil) {
+            callback(tox, conferenceNumber, peerNumber, messageType, message)
+        }
+        for _, subscriber := range tox.subscriptions[subscribeConferenceMessage].snapshot() {
+            subscriber.callback.(OnConferenceMessage)(tox, conferenceNumber, peerNumber, messageType, message)
+        }
+    })
+}
+
+// Dispatch a conference title event.
+func (tox *Tox) dispatchConferenceTitle(conferenceNumber uint32, peerNumber uint32, title []byte) {
+    var callback = tox.onConferenceTitle
+    tox.enqueue(func() {
+        if (callback != nil) {
+            callback(tox, conferenceNumber, peerNumber, title)
+        }
+        for _, subscriber := range tox.subscriptions[subscribeConferenceTitle].snapshot() {
+            subscriber.callback.(OnConferenceTitle)(tox, conferenceNumber, peerNumber, title)
+        }
+    })
+}
+
+// Dispatch a conference peer list changed event.
+func (tox *Tox) dispatchConferencePeerListChanged(conferenceNumber uint32) {
+    var callback = tox.onConferencePeerListChanged
+    tox.enqueue(func() {
+        if (callback != nil) {
+            callback(tox, conferenceNumber)
+        }
+        for _, subscriber := range tox.subscriptions[subscribeConferencePeerListChanged].snapshot() {
+            subscriber.callback.(OnConferencePeerListChanged)(tox, conferenceNumber)
+        }
+    })
+}
@@ -0,0 +1,236 @@
+/**
+ * File        : encryptsave.go
+ * Copyright   : Copyright (c) 2015-2017 Mirror Labs, Inc. All rights reserved.
+ * License     : GPLv3
+ * Maintainer  : Enzo Haussecker <enzo@mirror.co>, Dominic Williams <dominic@string.technology>
+ * Stability   : Experimental
+ * Portability : Non-portable (requires Tox core at commit dcf2aaa or c-toxcore 0.2)
+ *
+ * This module binds toxencryptsave, which encrypts save data with a key derived
+ * from a passphrase. Deriving a key is deliberately slow, so a pass key can be
+ * derived once and reused to encrypt and decrypt many times. Encrypted data
+ * carries the salt that the key was derived with, so the key for decrypting it
+ * can be derived again from the passphrase.
+ */
+
+package tox
+
+//#cgo !toxcore02 LDFLAGS: -l toxencryptsave
+//#include "encryptsave.h"
+import "C"
+import "crypto/rand"
+import "runtime"
+import "sync"
+
+////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////// STRUCT TYPES /////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// This type represents a key derived from a passphrase. It must be destroyed
+// once it is no longer needed, so that the key material is wiped from memory.
+type PassKey struct {
+
+    handle *C.pass_key
+    salt   ToxPassSalt
+    lock   sync.Mutex
+
+}
+
+// This type represents the salt used to derive a pass key.
+type ToxPassSalt [ToxPassSaltLength]byte
+
+////////////////////////////////////////////////////////////////////////////////
+////////////////////////////////// CONSTANTS ///////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// A set of numeric constants synonymous with their C-side counterparts.
+const (
+
+    ToxPassSaltLength             = C.TOX_PASS_SALT_LENGTH
+    ToxPassKeyLength              = C.TOX_PASS_KEY_LENGTH
+    ToxPassEncryptionExtraLength  = C.TOX_PASS_ENCRYPTION_EXTRA_LENGTH
+
+)
+
+////////////////////////////////////////////////////////////////////////////////
+//////////////////////////////// PASSPHRASE API ////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Encrypt data with a passphrase. The encrypted data is longer than the given
+// data by ToxPassEncryptionExtraLength bytes. This derives a new key, so use a
+// pass key to encrypt many times with the same passphrase.
+func EncryptSave(data []byte, passphrase []byte) (encrypted []byte, throw error) {
+    var c_error C.TOX_ERR_ENCRYPTION
+    encrypted = make([]byte, len(data) + ToxPassEncryptionExtraLength)
+    C.tox_pass_encrypt(
+        bytesOrNil(data),
+        C.size_t(len(data)),
+        bytesOrNil(passphrase),
+        C.size_t(len(passphrase)),
+        (*C.uint8_t)(&encrypted[0]),
+        &c_error,
+    )
+    if (c_error != C.TOX_ERR_ENCRYPTION_OK) {
+        return nil, errorEncryption("tox_pass_encrypt", c_error)
+    }
+    return
+}
+
+// Decrypt data that was encrypted with a passphrase.
+func DecryptSave(data []byte, passphrase []byte) (decrypted []byte, throw error) {
+    if (len(data) < ToxPassEncryptionExtraLength) {
+        return nil, ToxErrDecryptionInvalidLength
+    }
+    var c_error C.TOX_ERR_DECRYPTION
+    decrypted = make([]byte, len(data) - ToxPassEncryptionExtraLength)
+    C.tox_pass_decrypt(
+        (*C.uint8_t)(&data[0]),
+        C.size_t(len(data)),
+        bytesOrNil(passphrase),
+        C.size_t(len(passphrase)),
+        bytesOrNil(decrypted),
+        &c_error,
+    )
+    if (c_error != C.TOX_ERR_DECRYPTION_OK) {
+        return nil, errorDecryption("tox_pass_decrypt", c_error)
+    }
+    return
+}
+
+// Check whether data was encrypted by toxencryptsave.
+func IsDataEncrypted(data []byte) bool {
+    if (len(data) < ToxPassEncryptionExtraLength) {
+        return false
+    }
+    return bool(C.tox_is_data_encrypted((*C.uint8_t)(&data[0])))
+}
+
+// Get the salt that encrypted data was encrypted with. A pass key derived from
+// the passphrase and this salt decrypts the data.
+func GetSalt(data []byte) (salt ToxPassSalt, throw error) {
+    if (!IsDataEncrypted(data)) {
+        return salt, ToxErrDecryptionBadFormat
+    }
+    if (!bool(C.get_salt((*C.uint8_t)(&data[0]), (*C.uint8_t)(&salt[0])))) {
+        return salt, ToxErrDecryptionBadFormat
+    }
+    return
+}
+
+////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////// PASS KEY API /////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Derive a pass key from a passphrase with a random salt.
+func NewPassKey(passphrase []byte) (key *PassKey, throw error) {
+    var salt ToxPassSalt
+    _, throw = rand.Read(salt[:])
+    if throw != nil {
+        return
+    }
+    return NewPassKeyWithSalt(passphrase, salt)
+}
+
+// Derive a pass key from a passphrase and a salt. Use the salt of encrypted
+// data to derive the key that decrypts it.
+func NewPassKeyWithSalt(passphrase []byte, salt ToxPassSalt) (key *PassKey, throw error) {
+    var c_error C.TOX_ERR_KEY_DERIVATION
+    var c_key = C.pass_key_derive_with_salt(
+        bytesOrNil(passphrase),
+        C.size_t(len(passphrase)),
+        (*C.uint8_t)(&salt[0]),
+        &c_error,
+    )
+    if (c_error != C.TOX_ERR_KEY_DERIVATION_OK) {
+        return nil, errorKeyDerivation("tox_pass_key_derive_with_salt", c_error)
+    }
+    key = &PassKey {
+        handle: c_key,
+        salt: salt,
+    }
+    runtime.SetFinalizer(key, (*PassKey).Destroy)
+    return
+}
+
+// Get the salt that a pass key was derived with.
+func (key *PassKey) Salt() ToxPassSalt {
+    return key.salt
+}
+
+// Encrypt data with a pass key.
+func (key *PassKey) Encrypt(data []byte) (encrypted []byte, throw error) {
+    key.lock.Lock()
+    defer key.lock.Unlock()
+    if (key.handle == nil) {
+        return nil, ToxErrClosed
+    }
+    var c_error C.TOX_ERR_ENCRYPTION
+    encrypted = make([]byte, len(data) + ToxPassEncryptionExtraLength)
+    C.pass_key_encrypt(
+        key.handle,
+        bytesOrNil(data),
+        C.size_t(len(data)),
+        (*C.uint8_t)(&encrypted[0]),
+        &c_error,
+    )
+    if (c_error != C.TOX_ERR_ENCRYPTION_OK) {
+        return nil, errorEncryption("tox_pass_key_encrypt", c_error)
+    }
+    return
+}
+
+// Decrypt data with a pass key. The key must have been derived with the salt
+// of the data.
+func (key *PassKey) Decrypt(data []byte) (decrypted []byte, throw error) {
+    if (len(data) < ToxPassEncryptionExtraLength) {
+        return nil, ToxErrDecryptionInvalidLength
+    }
+    key.lock.Lock()
+    defer key.lock.Unlock()
+    if (key.handle == nil) {
+        return nil, ToxErrClosed
+    }
+    var c_error C.TOX_ERR_DECRYPTION
+    decrypted = make([]byte, len(data) - ToxPassEncryptionExtraLength)
+    C.pass_key_decrypt(
+        key.handle,
+        (*C.uint8_t)(&data[0]),
+        C.size_t(len(data)),
+        bytesOrNil(decrypted),
+        &c_error,
+    )
+    if (c_error != C.TOX_ERR_DECRYPTION_OK) {
+        return nil, errorDecryption("tox_pass_key_decrypt", c_error)
+    }
+    return
+}
+
+// Destroy a pass key, wiping the key material. It is safe to destroy a pass
+// key more than once. Calls made after the first return ToxErrClosed.
+func (key *PassKey) Destroy() {
+    key.lock.Lock()
+    defer key.lock.Unlock()
+    if (key.handle != nil) {
+        C.pass_key_free(key.handle)
+        key.handle = nil
+    }
+}
+
+////////////////////////////////////////////////////////////////////////////////
+////////////////////////////////// UTILITIES ///////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Get a C pointer to the first byte of a slice, or nil if the slice is empty.
+func bytesOrNil(data []byte) *C.uint8_t {
+    if (len(data) == 0) {
+        return nil
+    }
+    return (*C.uint8_t)(&data[0])
+}
+
+// Overwrite sensitive data with zeros.
+func wipe(data []byte) {
+    for i := range data {
+        data[i] = 0
+    }
+}
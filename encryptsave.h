@@ -0,0 +1,88 @@
+/**
+ * File        : encryptsave.h
+ * Copyright   : Copyright (c) 2015-2017 Mirror Labs, Inc. All rights reserved.
+ * License     : GPLv3
+ * Maintainer  : Enzo Haussecker <enzo@mirror.co>, Dominic Williams <dominic@string.technology>
+ * Stability   : Experimental
+ * Portability : Non-portable (requires Tox core at commit dcf2aaa or c-toxcore 0.2)
+ *
+ * This header hides the differences between the pass key APIs of the two
+ * supported versions of toxencryptsave. At commit dcf2aaa, a pass key is a
+ * struct owned by the caller, while c-toxcore 0.2 allocates an opaque pass key
+ * and takes the key before the data.
+ */
+
+#ifndef ENCRYPTSAVE_H
+#define ENCRYPTSAVE_H
+
+#include <stdbool.h>
+#include <stdint.h>
+#include <stdlib.h>
+#include <string.h>
+#include <tox/toxencryptsave.h>
+
+#ifdef TOXCORE_02
+
+typedef Tox_Pass_Key pass_key;
+
+static inline pass_key *pass_key_derive_with_salt(const uint8_t *passphrase, size_t length, const uint8_t *salt, TOX_ERR_KEY_DERIVATION *error) {
+    return tox_pass_key_derive_with_salt(passphrase, length, salt, error);
+}
+
+static inline void pass_key_free(pass_key *key) {
+    tox_pass_key_free(key);
+}
+
+static inline bool pass_key_encrypt(const pass_key *key, const uint8_t *data, size_t length, uint8_t *out, TOX_ERR_ENCRYPTION *error) {
+    return tox_pass_key_encrypt(key, data, length, out, error);
+}
+
+static inline bool pass_key_decrypt(const pass_key *key, const uint8_t *data, size_t length, uint8_t *out, TOX_ERR_DECRYPTION *error) {
+    return tox_pass_key_decrypt(key, data, length, out, error);
+}
+
+static inline bool get_salt(const uint8_t *data, uint8_t *salt) {
+    return tox_get_salt(data, salt, NULL);
+}
+
+#else
+
+typedef TOX_PASS_KEY pass_key;
+
+static inline pass_key *pass_key_derive_with_salt(const uint8_t *passphrase, size_t length, const uint8_t *salt, TOX_ERR_KEY_DERIVATION *error) {
+    pass_key *key = malloc(sizeof(pass_key));
+    if (key == NULL) {
+        *error = TOX_ERR_KEY_DERIVATION_FAILED;
+        return NULL;
+    }
+    if (!tox_derive_key_with_salt(passphrase, length, salt, key, error)) {
+        free(key);
+        return NULL;
+    }
+    return key;
+}
+
+// Wipe the key before releasing it, as c-toxcore 0.2 does.
+static inline void pass_key_free(pass_key *key) {
+    volatile uint8_t *p = (volatile uint8_t *) key;
+    for (size_t i = 0; i < sizeof(pass_key); i++) {
+        p[i] = 0;
+    }
+    free(key);
+}
+
+static inline bool pass_key_encrypt(const pass_key *key, const uint8_t *data, size_t length, uint8_t *out, TOX_ERR_ENCRYPTION *error) {
+    return tox_pass_key_encrypt(data, length, key, out, error);
+}
+
+static inline bool pass_key_decrypt(const pass_key *key, const uint8_t *data, size_t length, uint8_t *out, TOX_ERR_DECRYPTION *error) {
+    return tox_pass_key_decrypt(data, length, key, out, error);
+}
+
+static inline bool get_salt(const uint8_t *data, uint8_t *salt) {
+    return tox_get_salt(data, salt);
+}
+
+#endif
+
+#endif
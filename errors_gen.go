@@ -0,0 +1,593 @@
+// Code generated by toxgen from tox.h. DO NOT EDIT.
+
+/**
+ * File        : errors_gen.go
+ * Copyright   : Copyright (c) 2015-2017 Mirror Labs, Inc. All rights reserved.
+ * License     : GPLv3
+ * Maintainer  : Enzo Haussecker <enzo@mirror.co>, Dominic Williams <dominic@string.technology>
+ * Stability   : Experimental
+ * Portability : Non-portable (requires Tox core at commit dcf2aaa)
+ *
+ * This module defines an error for every error code in the Tox core headers,
+ * and a function for each error enum that maps its codes to these errors.
+ */
+
+package tox
+
+//#include <tox/tox.h>
+//#include <tox/toxencryptsave.h>
+import "C"
+import "errors"
+
+////////////////////////////////////////////////////////////////////////////////
+//////////////////////////////////// ERRORS ////////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// A collection of errors to indicate that a specific C-side error was received.
+var (
+
+    ToxErrOptionsNewMalloc                     = errors.New("The function failed to allocate enough memory for the options struct.")
+    ToxErrNewNull                              = errors.New("One of the arguments to the function was NULL when it was not expected.")
+    ToxErrNewMalloc                            = errors.New("The function was unable to allocate enough memory to store the internal structures for the Tox object.")
+    ToxErrNewPortAlloc                         = errors.New("The function was unable to bind to a port. This may mean that all ports have already been bound, e.g. by other Tox instances, or it may mean a permission error. You may be able to gather more information from errno.")
+    ToxErrNewProxyBadType                      = errors.New("proxy_type was invalid.")
+    ToxErrNewProxyBadHost                      = errors.New("proxy_type was valid, but the proxy_host passed had an invalid format or was NULL.")
+    ToxErrNewProxyBadPort                      = errors.New("proxy_type was valid, but the proxy_port was invalid.")
+    ToxErrNewProxyNotFound                     = errors.New("The proxy address passed could not be resolved.")
+    ToxErrNewLoadEncrypted                     = errors.New("The byte array to be loaded contained an encrypted save.")
+    ToxErrNewLoadBadFormat                     = errors.New("The data format was invalid. This can happen when loading data that was saved by an older version of Tox, or when the data has been corrupted. When loading from badly formatted data, some data may have been loaded, and the rest is discarded. Passing an invalid length parameter also causes this error.")
+    ToxErrBootstrapNull                        = errors.New("One of the arguments to the function was NULL when it was not expected.")
+    ToxErrBootstrapBadHost                     = errors.New("The address could not be resolved to an IP address, or the IP address passed was invalid.")
+    ToxErrBootstrapBadPort                     = errors.New("The port passed was invalid. The valid port range is (1, 65535).")
+    ToxErrSetInfoNull                          = errors.New("One of the arguments to the function was NULL when it was not expected.")
+    ToxErrSetInfoTooLong                       = errors.New("Information length exceeded maximum permissible size.")
+    ToxErrFriendAddNull                        = errors.New("One of the arguments to the function was NULL when it was not expected.")
+    ToxErrFriendAddTooLong                     = errors.New("The length of the friend request message exceeded TOX_MAX_FRIEND_REQUEST_LENGTH.")
+    ToxErrFriendAddNoMessage                   = errors.New("The friend request message was empty. This, and the TOO_LONG code will never be returned from tox_friend_add_norequest.")
+    ToxErrFriendAddOwnKey                      = errors.New("The friend address belongs to the sending client.")
+    ToxErrFriendAddAlreadySent                 = errors.New("A friend request has already been sent, or the address belongs to a friend that is already on the friend list.")
+    ToxErrFriendAddBadChecksum                 = errors.New("The friend address checksum failed.")
+    ToxErrFriendAddSetNewNoSpam                = errors.New("The friend was already there, but the nospam value was different.")
+    ToxErrFriendAddMalloc                      = errors.New("A memory allocation failed when trying to increase the friend list size.")
+    ToxErrFriendDeleteFriendNotFound           = errors.New("There was no friend with the given friend number. No friends were deleted.")
+    ToxErrFriendByPublicKeyNull                = errors.New("One of the arguments to the function was NULL when it was not expected.")
+    ToxErrFriendByPublicKeyNotFound            = errors.New("No friend with the given public key exists on the friend list.")
+    ToxErrFriendGetPublicKeyFriendNotFound     = errors.New("No friend with the given number exists on the friend list.")
+    ToxErrFriendGetLastOnlineFriendNotFound    = errors.New("No friend with the given number exists on the friend list.")
+    ToxErrFriendQueryNull                      = errors.New("The pointer parameter for storing the query result (name, message) was NULL. Unlike the _self_ variants of these functions, which have no effect when a parameter is NULL, these functions return an error in that case.")
+    ToxErrFriendQueryFriendNotFound            = errors.New("The friend number did not designate a valid friend.")
+    ToxErrSetTypingFriendNotFound              = errors.New("The friend number did not designate a valid friend.")
+    ToxErrFriendSendMessageNull                = errors.New("One of the arguments to the function was NULL when it was not expected.")
+    ToxErrFriendSendMessageFriendNotFound      = errors.New("The friend number did not designate a valid friend.")
+    ToxErrFriendSendMessageFriendNotConnected  = errors.New("This client is currently not connected to the friend.")
+    ToxErrFriendSendMessageSendQ               = errors.New("An allocation error occurred while increasing the send queue size.")
+    ToxErrFriendSendMessageTooLong             = errors.New("Message length exceeded TOX_MAX_MESSAGE_LENGTH.")
+    ToxErrFriendSendMessageEmpty               = errors.New("Attempted to send a zero-length message.")
+    ToxErrFileControlFriendNotFound            = errors.New("The friend number passed did not designate a valid friend.")
+    ToxErrFileControlFriendNotConnected        = errors.New("This client is currently not connected to the friend.")
+    ToxErrFileControlNotFound                  = errors.New("No file transfer with the given file number was found for the given friend.")
+    ToxErrFileControlNotPaused                 = errors.New("A RESUME control was sent, but the file transfer is running normally.")
+    ToxErrFileControlDenied                    = errors.New("A RESUME control was sent, but the file transfer was paused by the other party. Only the party that paused the transfer can resume it.")
+    ToxErrFileControlAlreadyPaused             = errors.New("A PAUSE control was sent, but the file transfer was already paused.")
+    ToxErrFileControlSendQ                     = errors.New("Packet queue is full.")
+    ToxErrFileSeekFriendNotFound               = errors.New("The friend number passed did not designate a valid friend.")
+    ToxErrFileSeekFriendNotConnected           = errors.New("This client is currently not connected to the friend.")
+    ToxErrFileSeekNotFound                     = errors.New("No file transfer with the given file number was found for the given friend.")
+    ToxErrFileSeekDenied                       = errors.New("File was not in a state where it could be seeked.")
+    ToxErrFileSeekInvalidPosition              = errors.New("Seek position was invalid.")
+    ToxErrFileSeekSendQ                        = errors.New("Packet queue is full.")
+    ToxErrFileGetNull                          = errors.New("One of the arguments to the function was NULL when it was not expected.")
+    ToxErrFileGetFriendNotFound                = errors.New("The friend number passed did not designate a valid friend.")
+    ToxErrFileGetNotFound                      = errors.New("No file transfer with the given file number was found for the given friend.")
+    ToxErrFileSendNull                         = errors.New("One of the arguments to the function was NULL when it was not expected.")
+    ToxErrFileSendFriendNotFound               = errors.New("The friend number passed did not designate a valid friend.")
+    ToxErrFileSendFriendNotConnected           = errors.New("This client is currently not connected to the friend.")
+    ToxErrFileSendNameTooLong                  = errors.New("Filename length exceeded TOX_MAX_FILENAME_LENGTH bytes.")
+    ToxErrFileSendTooMany                      = errors.New("Too many ongoing transfers. The maximum number of concurrent file transfers is 256 per friend per direction (sending and receiving).")
+    ToxErrFileSendChunkNull                    = errors.New("The length parameter was non-zero, but data was NULL.")
+    ToxErrFileSendChunkFriendNotFound          = errors.New("The friend number passed did not designate a valid friend.")
+    ToxErrFileSendChunkFriendNotConnected      = errors.New("This client is currently not connected to the friend.")
+    ToxErrFileSendChunkNotFound                = errors.New("No file transfer with the given file number was found for the given friend.")
+    ToxErrFileSendChunkNotTransferring         = errors.New("File transfer was found but isn't in a transferring state: (paused, done, broken, etc...) (happens only when not called from the request chunk callback).")
+    ToxErrFileSendChunkInvalidLength           = errors.New("Attempted to send more or less data than requested. The requested data size is adjusted according to maximum transmission unit and the expected end of the file. Trying to send less or more than requested will return this error.")
+    ToxErrFileSendChunkSendQ                   = errors.New("Packet queue is full.")
+    ToxErrFileSendChunkWrongPosition           = errors.New("Position parameter was wrong.")
+    ToxErrFriendCustomPacketNull               = errors.New("One of the arguments to the function was NULL when it was not expected.")
+    ToxErrFriendCustomPacketFriendNotFound     = errors.New("The friend number did not designate a valid friend.")
+    ToxErrFriendCustomPacketFriendNotConnected = errors.New("This client is currently not connected to the friend.")
+    ToxErrFriendCustomPacketInvalid            = errors.New("The first byte of data was not in the specified range for the packet type. This range is 200-254 for lossy, and 160-191 for lossless packets.")
+    ToxErrFriendCustomPacketEmpty              = errors.New("Attempted to send an empty packet.")
+    ToxErrFriendCustomPacketTooLong            = errors.New("Packet data length exceeded TOX_MAX_CUSTOM_PACKET_SIZE.")
+    ToxErrFriendCustomPacketSendQ              = errors.New("Packet queue is full.")
+    ToxErrGetPortNotBound                      = errors.New("The instance was not bound to any port.")
+    ToxErrKeyDerivationNull                    = errors.New("Some input data, or maybe the output pointer, was null.")
+    ToxErrKeyDerivationFailed                  = errors.New("The crypto lib was unable to derive a key from the given passphrase, which is usually a lack of memory issue. The functions accepting keys do not produce this error.")
+    ToxErrEncryptionNull                       = errors.New("Some input data, or maybe the output pointer, was null.")
+    ToxErrEncryptionKeyDerivationFailed        = errors.New("The crypto lib was unable to derive a key from the given passphrase, which is usually a lack of memory issue. The functions accepting keys do not produce this error.")
+    ToxErrEncryptionFailed                     = errors.New("The encryption itself failed.")
+    ToxErrDecryptionNull                       = errors.New("Some input data, or maybe the output pointer, was null.")
+    ToxErrDecryptionInvalidLength              = errors.New("The input data was shorter than TOX_PASS_ENCRYPTION_EXTRA_LENGTH bytes")
+    ToxErrDecryptionBadFormat                  = errors.New("The input data is missing the magic number (i.e. wasn't created by this module, or is corrupted)")
+    ToxErrDecryptionKeyDerivationFailed        = errors.New("The crypto lib was unable to derive a key from the given passphrase, which is usually a lack of memory issue. The functions accepting keys do not produce this error.")
+    ToxErrDecryptionFailed                     = errors.New("The encrypted byte array could not be decrypted. Either the data was corrupted or the password/key was incorrect.")
+
+)
+
+// The category of each sentinel error that corresponds to a C-side error.
+var toxErrorCategories = map[error]ToxErrorCategory {
+    ToxErrOptionsNewMalloc:                     ToxErrorCategoryResource,
+    ToxErrNewNull:                              ToxErrorCategoryArgument,
+    ToxErrNewMalloc:                            ToxErrorCategoryResource,
+    ToxErrNewPortAlloc:                         ToxErrorCategoryResource,
+    ToxErrNewProxyBadType:                      ToxErrorCategoryArgument,
+    ToxErrNewProxyBadHost:                      ToxErrorCategoryArgument,
+    ToxErrNewProxyBadPort:                      ToxErrorCategoryArgument,
+    ToxErrNewProxyNotFound:                     ToxErrorCategoryNetwork,
+    ToxErrNewLoadEncrypted:                     ToxErrorCategoryArgument,
+    ToxErrNewLoadBadFormat:                     ToxErrorCategoryArgument,
+    ToxErrBootstrapNull:                        ToxErrorCategoryArgument,
+    ToxErrBootstrapBadHost:                     ToxErrorCategoryArgument,
+    ToxErrBootstrapBadPort:                     ToxErrorCategoryArgument,
+    ToxErrSetInfoNull:                          ToxErrorCategoryArgument,
+    ToxErrSetInfoTooLong:                       ToxErrorCategoryArgument,
+    ToxErrFriendAddNull:                        ToxErrorCategoryArgument,
+    ToxErrFriendAddTooLong:                     ToxErrorCategoryArgument,
+    ToxErrFriendAddNoMessage:                   ToxErrorCategoryArgument,
+    ToxErrFriendAddOwnKey:                      ToxErrorCategoryState,
+    ToxErrFriendAddAlreadySent:                 ToxErrorCategoryState,
+    ToxErrFriendAddBadChecksum:                 ToxErrorCategoryArgument,
+    ToxErrFriendAddSetNewNoSpam:                ToxErrorCategoryState,
+    ToxErrFriendAddMalloc:                      ToxErrorCategoryResource,
+    ToxErrFriendDeleteFriendNotFound:           ToxErrorCategoryNotFound,
+    ToxErrFriendByPublicKeyNull:                ToxErrorCategoryArgument,
+    ToxErrFriendByPublicKeyNotFound:            ToxErrorCategoryNotFound,
+    ToxErrFriendGetPublicKeyFriendNotFound:     ToxErrorCategoryNotFound,
+    ToxErrFriendGetLastOnlineFriendNotFound:    ToxErrorCategoryNotFound,
+    ToxErrFriendQueryNull:                      ToxErrorCategoryArgument,
+    ToxErrFriendQueryFriendNotFound:            ToxErrorCategoryNotFound,
+    ToxErrSetTypingFriendNotFound:              ToxErrorCategoryNotFound,
+    ToxErrFriendSendMessageNull:                ToxErrorCategoryArgument,
+    ToxErrFriendSendMessageFriendNotFound:      ToxErrorCategoryNotFound,
+    ToxErrFriendSendMessageFriendNotConnected:  ToxErrorCategoryNetwork,
+    ToxErrFriendSendMessageSendQ:               ToxErrorCategoryResource,
+    ToxErrFriendSendMessageTooLong:             ToxErrorCategoryArgument,
+    ToxErrFriendSendMessageEmpty:               ToxErrorCategoryArgument,
+    ToxErrFileControlFriendNotFound:            ToxErrorCategoryNotFound,
+    ToxErrFileControlFriendNotConnected:        ToxErrorCategoryNetwork,
+    ToxErrFileControlNotFound:                  ToxErrorCategoryNotFound,
+    ToxErrFileControlNotPaused:                 ToxErrorCategoryState,
+    ToxErrFileControlDenied:                    ToxErrorCategoryState,
+    ToxErrFileControlAlreadyPaused:             ToxErrorCategoryState,
+    ToxErrFileControlSendQ:                     ToxErrorCategoryResource,
+    ToxErrFileSeekFriendNotFound:               ToxErrorCategoryNotFound,
+    ToxErrFileSeekFriendNotConnected:           ToxErrorCategoryNetwork,
+    ToxErrFileSeekNotFound:                     ToxErrorCategoryNotFound,
+    ToxErrFileSeekDenied:                       ToxErrorCategoryState,
+    ToxErrFileSeekInvalidPosition:              ToxErrorCategoryArgument,
+    ToxErrFileSeekSendQ:                        ToxErrorCategoryResource,
+    ToxErrFileGetNull:                          ToxErrorCategoryArgument,
+    ToxErrFileGetFriendNotFound:                ToxErrorCategoryNotFound,
+    ToxErrFileGetNotFound:                      ToxErrorCategoryNotFound,
+    ToxErrFileSendNull:                         ToxErrorCategoryArgument,
+    ToxErrFileSendFriendNotFound:               ToxErrorCategoryNotFound,
+    ToxErrFileSendFriendNotConnected:           ToxErrorCategoryNetwork,
+    ToxErrFileSendNameTooLong:                  ToxErrorCategoryArgument,
+    ToxErrFileSendTooMany:                      ToxErrorCategoryResource,
+    ToxErrFileSendChunkNull:                    ToxErrorCategoryArgument,
+    ToxErrFileSendChunkFriendNotFound:          ToxErrorCategoryNotFound,
+    ToxErrFileSendChunkFriendNotConnected:      ToxErrorCategoryNetwork,
+    ToxErrFileSendChunkNotFound:                ToxErrorCategoryNotFound,
+    ToxErrFileSendChunkNotTransferring:         ToxErrorCategoryState,
+    ToxErrFileSendChunkInvalidLength:           ToxErrorCategoryArgument,
+    ToxErrFileSendChunkSendQ:                   ToxErrorCategoryResource,
+    ToxErrFileSendChunkWrongPosition:           ToxErrorCategoryArgument,
+    ToxErrFriendCustomPacketNull:               ToxErrorCategoryArgument,
+    ToxErrFriendCustomPacketFriendNotFound:     ToxErrorCategoryNotFound,
+    ToxErrFriendCustomPacketFriendNotConnected: ToxErrorCategoryNetwork,
+    ToxErrFriendCustomPacketInvalid:            ToxErrorCategoryArgument,
+    ToxErrFriendCustomPacketEmpty:              ToxErrorCategoryArgument,
+    ToxErrFriendCustomPacketTooLong:            ToxErrorCategoryArgument,
+    ToxErrFriendCustomPacketSendQ:              ToxErrorCategoryResource,
+    ToxErrGetPortNotBound:                      ToxErrorCategoryState,
+    ToxErrKeyDerivationNull:                    ToxErrorCategoryArgument,
+    ToxErrKeyDerivationFailed:                  ToxErrorCategoryResource,
+    ToxErrEncryptionNull:                       ToxErrorCategoryArgument,
+    ToxErrEncryptionKeyDerivationFailed:        ToxErrorCategoryResource,
+    ToxErrDecryptionNull:                       ToxErrorCategoryArgument,
+    ToxErrDecryptionInvalidLength:              ToxErrorCategoryArgument,
+    ToxErrDecryptionBadFormat:                  ToxErrorCategoryArgument,
+    ToxErrDecryptionKeyDerivationFailed:        ToxErrorCategoryResource,
+    ToxErrDecryptionFailed:                     ToxErrorCategoryArgument,
+}
+
+////////////////////////////////////////////////////////////////////////////////
+//////////////////////////////// ERROR MAPPING /////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Map a TOX_ERR_OPTIONS_NEW code to an error.
+func errorOptionsNew(op string, c_error C.TOX_ERR_OPTIONS_NEW) error {
+    var throw error
+    switch c_error {
+        case C.TOX_ERR_OPTIONS_NEW_MALLOC:
+            throw = ToxErrOptionsNewMalloc
+        default:
+            throw = ToxErrUnknown
+    }
+    return newToxError(op, int(c_error), throw)
+}
+
+// Map a TOX_ERR_NEW code to an error.
+func errorNew(op string, c_error C.TOX_ERR_NEW) error {
+    var throw error
+    switch c_error {
+        case C.TOX_ERR_NEW_NULL:
+            throw = ToxErrNewNull
+        case C.TOX_ERR_NEW_MALLOC:
+            throw = ToxErrNewMalloc
+        case C.TOX_ERR_NEW_PORT_ALLOC:
+            throw = ToxErrNewPortAlloc
+        case C.TOX_ERR_NEW_PROXY_BAD_TYPE:
+            throw = ToxErrNewProxyBadType
+        case C.TOX_ERR_NEW_PROXY_BAD_HOST:
+            throw = ToxErrNewProxyBadHost
+        case C.TOX_ERR_NEW_PROXY_BAD_PORT:
+            throw = ToxErrNewProxyBadPort
+        case C.TOX_ERR_NEW_PROXY_NOT_FOUND:
+            throw = ToxErrNewProxyNotFound
+        case C.TOX_ERR_NEW_LOAD_ENCRYPTED:
+            throw = ToxErrNewLoadEncrypted
+        case C.TOX_ERR_NEW_LOAD_BAD_FORMAT:
+            throw = ToxErrNewLoadBadFormat
+        default:
+            throw = ToxErrUnknown
+    }
+    return newToxError(op, int(c_error), throw)
+}
+
+// Map a TOX_ERR_BOOTSTRAP code to an error.
+func errorBootstrap(op string, c_error C.TOX_ERR_BOOTSTRAP) error {
+    var throw error
+    switch c_error {
+        case C.TOX_ERR_BOOTSTRAP_NULL:
+            throw = ToxErrBootstrapNull
+        case C.TOX_ERR_BOOTSTRAP_BAD_HOST:
+            throw = ToxErrBootstrapBadHost
+        case C.TOX_ERR_BOOTSTRAP_BAD_PORT:
+            throw = ToxErrBootstrapBadPort
+        default:
+            throw = ToxErrUnknown
+    }
+    return newToxError(op, int(c_error), throw)
+}
+
+// Map a TOX_ERR_SET_INFO code to an error.
+func errorSetInfo(op string, c_error C.TOX_ERR_SET_INFO) error {
+    var throw error
+    switch c_error {
+        case C.TOX_ERR_SET_INFO_NULL:
+            throw = ToxErrSetInfoNull
+        case C.TOX_ERR_SET_INFO_TOO_LONG:
+            throw = ToxErrSetInfoTooLong
+        default:
+            throw = ToxErrUnknown
+    }
+    return newToxError(op, int(c_error), throw)
+}
+
+// Map a TOX_ERR_FRIEND_ADD code to an error.
+func errorFriendAdd(op string, c_error C.TOX_ERR_FRIEND_ADD) error {
+    var throw error
+    switch c_error {
+        case C.TOX_ERR_FRIEND_ADD_NULL:
+            throw = ToxErrFriendAddNull
+        case C.TOX_ERR_FRIEND_ADD_TOO_LONG:
+            throw = ToxErrFriendAddTooLong
+        case C.TOX_ERR_FRIEND_ADD_NO_MESSAGE:
+            throw = ToxErrFriendAddNoMessage
+        case C.TOX_ERR_FRIEND_ADD_OWN_KEY:
+            throw = ToxErrFriendAddOwnKey
+        case C.TOX_ERR_FRIEND_ADD_ALREADY_SENT:
+            throw = ToxErrFriendAddAlreadySent
+        case C.TOX_ERR_FRIEND_ADD_BAD_CHECKSUM:
+            throw = ToxErrFriendAddBadChecksum
+        case C.TOX_ERR_FRIEND_ADD_SET_NEW_NOSPAM:
+            throw = ToxErrFriendAddSetNewNoSpam
+        case C.TOX_ERR_FRIEND_ADD_MALLOC:
+            throw = ToxErrFriendAddMalloc
+        default:
+            throw = ToxErrUnknown
+    }
+    return newToxError(op, int(c_error), throw)
+}
+
+// Map a TOX_ERR_FRIEND_DELETE code to an error.
+func errorFriendDelete(op string, c_error C.TOX_ERR_FRIEND_DELETE) error {
+    var throw error
+    switch c_error {
+        case C.TOX_ERR_FRIEND_DELETE_FRIEND_NOT_FOUND:
+            throw = ToxErrFriendDeleteFriendNotFound
+        default:
+            throw = ToxErrUnknown
+    }
+    return newToxError(op, int(c_error), throw)
+}
+
+// Map a TOX_ERR_FRIEND_BY_PUBLIC_KEY code to an error.
+func errorFriendByPublicKey(op string, c_error C.TOX_ERR_FRIEND_BY_PUBLIC_KEY) error {
+    var throw error
+    switch c_error {
+        case C.TOX_ERR_FRIEND_BY_PUBLIC_KEY_NULL:
+            throw = ToxErrFriendByPublicKeyNull
+        case C.TOX_ERR_FRIEND_BY_PUBLIC_KEY_NOT_FOUND:
+            throw = ToxErrFriendByPublicKeyNotFound
+        default:
+            throw = ToxErrUnknown
+    }
+    return newToxError(op, int(c_error), throw)
+}
+
+// Map a TOX_ERR_FRIEND_GET_PUBLIC_KEY code to an error.
+func errorFriendGetPublicKey(op string, c_error C.TOX_ERR_FRIEND_GET_PUBLIC_KEY) error {
+    var throw error
+    switch c_error {
+        case C.TOX_ERR_FRIEND_GET_PUBLIC_KEY_FRIEND_NOT_FOUND:
+            throw = ToxErrFriendGetPublicKeyFriendNotFound
+        default:
+            throw = ToxErrUnknown
+    }
+    return newToxError(op, int(c_error), throw)
+}
+
+// Map a TOX_ERR_FRIEND_GET_LAST_ONLINE code to an error.
+func errorFriendGetLastOnline(op string, c_error C.TOX_ERR_FRIEND_GET_LAST_ONLINE) error {
+    var throw error
+    switch c_error {
+        case C.TOX_ERR_FRIEND_GET_LAST_ONLINE_FRIEND_NOT_FOUND:
+            throw = ToxErrFriendGetLastOnlineFriendNotFound
+        default:
+            throw = ToxErrUnknown
+    }
+    return newToxError(op, int(c_error), throw)
+}
+
+// Map a TOX_ERR_FRIEND_QUERY code to an error.
+func errorFriendQuery(op string, c_error C.TOX_ERR_FRIEND_QUERY) error {
+    var throw error
+    switch c_error {
+        case C.TOX_ERR_FRIEND_QUERY_NULL:
+            throw = ToxErrFriendQueryNull
+        case C.TOX_ERR_FRIEND_QUERY_FRIEND_NOT_FOUND:
+            throw = ToxErrFriendQueryFriendNotFound
+        default:
+            throw = ToxErrUnknown
+    }
+    return newToxError(op, int(c_error), throw)
+}
+
+// Map a TOX_ERR_SET_TYPING code to an error.
+func errorSetTyping(op string, c_error C.TOX_ERR_SET_TYPING) error {
+    var throw error
+    switch c_error {
+        case C.TOX_ERR_SET_TYPING_FRIEND_NOT_FOUND:
+            throw = ToxErrSetTypingFriendNotFound
+        default:
+            throw = ToxErrUnknown
+    }
+    return newToxError(op, int(c_error), throw)
+}
+
+// Map a TOX_ERR_FRIEND_SEND_MESSAGE code to an error.
+func errorFriendSendMessage(op string, c_error C.TOX_ERR_FRIEND_SEND_MESSAGE) error {
+    var throw error
+    switch c_error {
+        case C.TOX_ERR_FRIEND_SEND_MESSAGE_NULL:
+            throw = ToxErrFriendSendMessageNull
+        case C.TOX_ERR_FRIEND_SEND_MESSAGE_FRIEND_NOT_FOUND:
+            throw = ToxErrFriendSendMessageFriendNotFound
+        case C.TOX_ERR_FRIEND_SEND_MESSAGE_FRIEND_NOT_CONNECTED:
+            throw = ToxErrFriendSendMessageFriendNotConnected
+        case C.TOX_ERR_FRIEND_SEND_MESSAGE_SENDQ:
+            throw = ToxErrFriendSendMessageSendQ
+        case C.TOX_ERR_FRIEND_SEND_MESSAGE_TOO_LONG:
+            throw = ToxErrFriendSendMessageTooLong
+        case C.TOX_ERR_FRIEND_SEND_MESSAGE_EMPTY:
+            throw = ToxErrFriendSendMessageEmpty
+        default:
+            throw = ToxErrUnknown
+    }
+    return newToxError(op, int(c_error), throw)
+}
+
+// Map a TOX_ERR_FILE_CONTROL code to an error.
+func errorFileControl(op string, c_error C.TOX_ERR_FILE_CONTROL) error {
+    var throw error
+    switch c_error {
+        case C.TOX_ERR_FILE_CONTROL_FRIEND_NOT_FOUND:
+            throw = ToxErrFileControlFriendNotFound
+        case C.TOX_ERR_FILE_CONTROL_FRIEND_NOT_CONNECTED:
+            throw = ToxErrFileControlFriendNotConnected
+        case C.TOX_ERR_FILE_CONTROL_NOT_FOUND:
+            throw = ToxErrFileControlNotFound
+        case C.TOX_ERR_FILE_CONTROL_NOT_PAUSED:
+            throw = ToxErrFileControlNotPaused
+        case C.TOX_ERR_FILE_CONTROL_DENIED:
+            throw = ToxErrFileControlDenied
+        case C.TOX_ERR_FILE_CONTROL_ALREADY_PAUSED:
+            throw = ToxErrFileControlAlreadyPaused
+        case C.TOX_ERR_FILE_CONTROL_SENDQ:
+            throw = ToxErrFileControlSendQ
+        default:
+            throw = ToxErrUnknown
+    }
+    return newToxError(op, int(c_error), throw)
+}
+
+// Map a TOX_ERR_FILE_SEEK code to an error.
+func errorFileSeek(op string, c_error C.TOX_ERR_FILE_SEEK) error {
+    var throw error
+    switch c_error {
+        case C.TOX_ERR_FILE_SEEK_FRIEND_NOT_FOUND:
+            throw = ToxErrFileSeekFriendNotFound
+        case C.TOX_ERR_FILE_SEEK_FRIEND_NOT_CONNECTED:
+            throw = ToxErrFileSeekFriendNotConnected
+        case C.TOX_ERR_FILE_SEEK_NOT_FOUND:
+            throw = ToxErrFileSeekNotFound
+        case C.TOX_ERR_FILE_SEEK_DENIED:
+            throw = ToxErrFileSeekDenied
+        case C.TOX_ERR_FILE_SEEK_INVALID_POSITION:
+            throw = ToxErrFileSeekInvalidPosition
+        case C.TOX_ERR_FILE_SEEK_SENDQ:
+            throw = ToxErrFileSeekSendQ
+        default:
+            throw = ToxErrUnknown
+    }
+    return newToxError(op, int(c_error), throw)
+}
+
+// Map a TOX_ERR_FILE_GET code to an error.
+func errorFileGet(op string, c_error C.TOX_ERR_FILE_GET) error {
+    var throw error
+    switch c_error {
+        case C.TOX_ERR_FILE_GET_NULL:
+            throw = ToxErrFileGetNull
+        case C.TOX_ERR_FILE_GET_FRIEND_NOT_FOUND:
+            throw = ToxErrFileGetFriendNotFound
+        case C.TOX_ERR_FILE_GET_NOT_FOUND:
+            throw = ToxErrFileGetNotFound
+        default:
+            throw = ToxErrUnknown
+    }
+    return newToxError(op, int(c_error), throw)
+}
+
+// Map a TOX_ERR_FILE_SEND code to an error.
+func errorFileSend(op string, c_error C.TOX_ERR_FILE_SEND) error {
+    var throw error
+    switch c_error {
+        case C.TOX_ERR_FILE_SEND_NULL:
+            throw = ToxErrFileSendNull
+        case C.TOX_ERR_FILE_SEND_FRIEND_NOT_FOUND:
+            throw = ToxErrFileSendFriendNotFound
+        case C.TOX_ERR_FILE_SEND_FRIEND_NOT_CONNECTED:
+            throw = ToxErrFileSendFriendNotConnected
+        case C.TOX_ERR_FILE_SEND_NAME_TOO_LONG:
+            throw = ToxErrFileSendNameTooLong
+        case C.TOX_ERR_FILE_SEND_TOO_MANY:
+            throw = ToxErrFileSendTooMany
+        default:
+            throw = ToxErrUnknown
+    }
+    return newToxError(op, int(c_error), throw)
+}
+
+// Map a TOX_ERR_FILE_SEND_CHUNK code to an error.
+func errorFileSendChunk(op string, c_error C.TOX_ERR_FILE_SEND_CHUNK) error {
+    var throw error
+    switch c_error {
+        case C.TOX_ERR_FILE_SEND_CHUNK_NULL:
+            throw = ToxErrFileSendChunkNull
+        case C.TOX_ERR_FILE_SEND_CHUNK_FRIEND_NOT_FOUND:
+            throw = ToxErrFileSendChunkFriendNotFound
+        case C.TOX_ERR_FILE_SEND_CHUNK_FRIEND_NOT_CONNECTED:
+            throw = ToxErrFileSendChunkFriendNotConnected
+        case C.TOX_ERR_FILE_SEND_CHUNK_NOT_FOUND:
+            throw = ToxErrFileSendChunkNotFound
+        case C.TOX_ERR_FILE_SEND_CHUNK_NOT_TRANSFERRING:
+            throw = ToxErrFileSendChunkNotTransferring
+        case C.TOX_ERR_FILE_SEND_CHUNK_INVALID_LENGTH:
+            throw = ToxErrFileSendChunkInvalidLength
+        case C.TOX_ERR_FILE_SEND_CHUNK_SENDQ:
+            throw = ToxErrFileSendChunkSendQ
+        case C.TOX_ERR_FILE_SEND_CHUNK_WRONG_POSITION:
+            throw = ToxErrFileSendChunkWrongPosition
+        default:
+            throw = ToxErrUnknown
+    }
+    return newToxError(op, int(c_error), throw)
+}
+
+// Map a TOX_ERR_FRIEND_CUSTOM_PACKET code to an error.
+func errorFriendCustomPacket(op string, c_error C.TOX_ERR_FRIEND_CUSTOM_PACKET) error {
+    var throw error
+    switch c_error {
+        case C.TOX_ERR_FRIEND_CUSTOM_PACKET_NULL:
+            throw = ToxErrFriendCustomPacketNull
+        case C.TOX_ERR_FRIEND_CUSTOM_PACKET_FRIEND_NOT_FOUND:
+            throw = ToxErrFriendCustomPacketFriendNotFound
+        case C.TOX_ERR_FRIEND_CUSTOM_PACKET_FRIEND_NOT_CONNECTED:
+            throw = ToxErrFriendCustomPacketFriendNotConnected
+        case C.TOX_ERR_FRIEND_CUSTOM_PACKET_INVALID:
+            throw = ToxErrFriendCustomPacketInvalid
+        case C.TOX_ERR_FRIEND_CUSTOM_PACKET_EMPTY:
+            throw = ToxErrFriendCustomPacketEmpty
+        case C.TOX_ERR_FRIEND_CUSTOM_PACKET_TOO_LONG:
+            throw = ToxErrFriendCustomPacketTooLong
+        case C.TOX_ERR_FRIEND_CUSTOM_PACKET_SENDQ:
+            throw = ToxErrFriendCustomPacketSendQ
+        default:
+            throw = ToxErrUnknown
+    }
+    return newToxError(op, int(c_error), throw)
+}
+
+// Map a TOX_ERR_GET_PORT code to an error.
+func errorGetPort(op string, c_error C.TOX_ERR_GET_PORT) error {
+    var throw error
+    switch c_error {
+        case C.TOX_ERR_GET_PORT_NOT_BOUND:
+            throw = ToxErrGetPortNotBound
+        default:
+            throw = ToxErrUnknown
+    }
+    return newToxError(op, int(c_error), throw)
+}
+
+// Map a TOX_ERR_KEY_DERIVATION code to an error.
+func errorKeyDerivation(op string, c_error C.TOX_ERR_KEY_DERIVATION) error {
+    var throw error
+    switch c_error {
+        case C.TOX_ERR_KEY_DERIVATION_NULL:
+            throw = ToxErrKeyDerivationNull
+        case C.TOX_ERR_KEY_DERIVATION_FAILED:
+            throw = ToxErrKeyDerivationFailed
+        default:
+            throw = ToxErrUnknown
+    }
+    return newToxError(op, int(c_error), throw)
+}
+
+// Map a TOX_ERR_ENCRYPTION code to an error.
+func errorEncryption(op string, c_error C.TOX_ERR_ENCRYPTION) error {
+    var throw error
+    switch c_error {
+        case C.TOX_ERR_ENCRYPTION_NULL:
+            throw = ToxErrEncryptionNull
+        case C.TOX_ERR_ENCRYPTION_KEY_DERIVATION_FAILED:
+            throw = ToxErrEncryptionKeyDerivationFailed
+        case C.TOX_ERR_ENCRYPTION_FAILED:
+            throw = ToxErrEncryptionFailed
+        default:
+            throw = ToxErrUnknown
+    }
+    return newToxError(op, int(c_error), throw)
+}
+
+// Map a TOX_ERR_DECRYPTION code to an error.
+func errorDecryption(op string, c_error C.TOX_ERR_DECRYPTION) error {
+    var throw error
+    switch c_error {
+        case C.TOX_ERR_DECRYPTION_NULL:
+            throw = ToxErrDecryptionNull
+        case C.TOX_ERR_DECRYPTION_INVALID_LENGTH:
+            throw = ToxErrDecryptionInvalidLength
+        case C.TOX_ERR_DECRYPTION_BAD_FORMAT:
+            throw = ToxErrDecryptionBadFormat
+        case C.TOX_ERR_DECRYPTION_KEY_DERIVATION_FAILED:
+            throw = ToxErrDecryptionKeyDerivationFailed
+        case C.TOX_ERR_DECRYPTION_FAILED:
+            throw = ToxErrDecryptionFailed
+        default:
+            throw = ToxErrUnknown
+    }
+    return newToxError(op, int(c_error), throw)
+}
@@ -0,0 +1,253 @@
+/**
+ * File        : events.go
+ * Copyright   : Copyright (c) 2015-2017 Mirror Labs, Inc. All rights reserved.
+ * License     : GPLv3
+ * Maintainer  : Enzo Haussecker <enzo@mirror.co>, Dominic Williams <dominic@string.technology>
+ * Stability   : Experimental
+ * Portability : Non-portable (requires Tox core at commit dcf2aaa)
+ *
+ * This module delivers events over channels as an alternative to callbacks.
+ * Any number of event streams can be opened, and they coexist with the
+ * callbacks registered through the setters. A stream is closed when it is
+ * cancelled, or when the Tox instance is destroyed.
+ */
+
+package tox
+
+//#include "callbacks.h"
+import "C"
+import "sync"
+
+////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////// STRUCT TYPES /////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// This type represents an event delivered by an event stream. It is one of
+// the event types defined below.
+type Event interface {
+    isEvent()
+}
+
+// This event occurs when the connection status of the client changes.
+type SelfConnectionStatusEvent struct {
+    ConnectionStatus ToxConnectionStatus
+}
+
+// This event occurs when receiving a friend request.
+type FriendRequestEvent struct {
+    PublicKey ToxPublicKey
+    Message   []byte
+}
+
+// This event occurs when a friend changes their name.
+type FriendNameEvent struct {
+    FriendNumber uint32
+    Name         []byte
+}
+
+// This event occurs when a friend changes their user status.
+type FriendStatusEvent struct {
+    FriendNumber uint32
+    UserStatus   ToxUserStatus
+}
+
+// This event occurs when a friend changes their status message.
+type FriendStatusMessageEvent struct {
+    FriendNumber  uint32
+    StatusMessage []byte
+}
+
+// This event occurs when the connection status of a friend changes.
+type FriendConnectionStatusEvent struct {
+    FriendNumber     uint32
+    ConnectionStatus ToxConnectionStatus
+}
+
+// This event occurs when receiving a chat message from a friend.
+type FriendMessageEvent struct {
+    FriendNumber uint32
+    MessageType  ToxMessageType
+    Message      []byte
+}
+
+// This event occurs when receiving a lossless custom packet from a friend.
+type FriendLosslessPacketEvent struct {
+    FriendNumber uint32
+    Data         []byte
+}
+
+func (SelfConnectionStatusEvent) isEvent() {}
+func (FriendRequestEvent) isEvent() {}
+func (FriendNameEvent) isEvent() {}
+func (FriendStatusEvent) isEvent() {}
+func (FriendStatusMessageEvent) isEvent() {}
+func (FriendConnectionStatusEvent) isEvent() {}
+func (FriendMessageEvent) isEvent() {}
+func (FriendLosslessPacketEvent) isEvent() {}
+
+// This type holds the state of an event stream.
+type eventStream struct {
+    events chan Event
+    policy OverflowPolicy
+    lock   sync.RWMutex
+    closed chan struct{}
+    once   sync.Once
+}
+
+////////////////////////////////////////////////////////////////////////////////
+////////////////////////////////// ENUM TYPES //////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// This type represents what an event stream does with an event when its
+// buffer is full.
+type OverflowPolicy int
+
+const (
+
+    // Wait until the receiver makes room for the event. This stalls the event
+    // loop, so the receiver must keep up.
+    OverflowBlock OverflowPolicy = iota
+
+    // Discard the oldest buffered event to make room for the event. If the
+    // stream is unbuffered, then the event is discarded instead.
+    OverflowDropOldest
+
+    // Discard the event.
+    OverflowDropNewest
+
+)
+
+////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////// EVENT STREAMS ////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Open an event stream with the given buffer size and, optionally, overflow
+// policy. If no overflow policy is given, then OverflowBlock is used. The
+// stream receives self connection, friend request, friend name, friend status,
+// friend status message, friend connection, friend message and lossless packet
+// events. This returns a function that cancels the stream. The channel is
+// closed when the stream is cancelled, when the Tox instance is destroyed, or
+// right away if it has already been destroyed. A cancelled stream no longer
+// holds up the event loop, even under OverflowBlock.
+func (tox *Tox) Events(bufferSize int, policy ...OverflowPolicy) (events <-chan Event, cancel func()) {
+    if (bufferSize < 0) {
+        bufferSize = 0
+    }
+    var overflow = OverflowBlock
+    if (len(policy) > 0) {
+        overflow = policy[0]
+    }
+    var stream = newEventStream(bufferSize, overflow)
+    tox.streamsLock.Lock()
+    tox.streams = append(tox.streams, stream)
+    tox.streamsLock.Unlock()
+    tox.exec(nil, func() {
+        C.register_self_connection_status(tox.handle, C.uintptr_t(tox.id))
+        C.register_friend_request(tox.handle, C.uintptr_t(tox.id))
+        C.register_friend_name(tox.handle, C.uintptr_t(tox.id))
+        C.register_friend_status(tox.handle, C.uintptr_t(tox.id))
+        C.register_friend_status_message(tox.handle, C.uintptr_t(tox.id))
+        C.register_friend_connection_status(tox.handle, C.uintptr_t(tox.id))
+        C.register_friend_message(tox.handle, C.uintptr_t(tox.id))
+        C.register_friend_lossless_packet(tox.handle, C.uintptr_t(tox.id))
+    })
+    if (tox.closed()) {
+        tox.closeStreams()
+    }
+    return stream.events, func() {
+        tox.removeStream(stream)
+    }
+}
+
+// Deliver an event to every open event stream.
+func (tox *Tox) publish(event Event) {
+    tox.streamsLock.Lock()
+    var streams = tox.streams
+    tox.streamsLock.Unlock()
+    for _, stream := range streams {
+        stream.send(event)
+    }
+}
+
+// Close every open event stream.
+func (tox *Tox) closeStreams() {
+    tox.streamsLock.Lock()
+    var streams = tox.streams
+    tox.streams = nil
+    tox.streamsLock.Unlock()
+    for _, stream := range streams {
+        stream.close()
+    }
+}
+
+// Close an event stream and stop delivering events to it.
+func (tox *Tox) removeStream(stream *eventStream) {
+    tox.streamsLock.Lock()
+    for i, open := range tox.streams {
+        if (open == stream) {
+            tox.streams = append(tox.streams[:i:i], tox.streams[i + 1:]...)
+            break
+        }
+    }
+    tox.streamsLock.Unlock()
+    stream.close()
+}
+
+// Create an event stream.
+func newEventStream(bufferSize int, policy OverflowPolicy) *eventStream {
+    return &eventStream {
+        events: make(chan Event, bufferSize),
+        policy: policy,
+        closed: make(chan struct{}),
+    }
+}
+
+// Close an event stream. A sender blocked on the stream gives up, so that the
+// channel can be closed. Closing a stream again has no effect.
+func (stream *eventStream) close() {
+    stream.once.Do(func() {
+        close(stream.closed)
+        stream.lock.Lock()
+        close(stream.events)
+        stream.lock.Unlock()
+    })
+}
+
+// Deliver an event to a stream according to its overflow policy.
+func (stream *eventStream) send(event Event) {
+    stream.lock.RLock()
+    defer stream.lock.RUnlock()
+    select {
+        case <-stream.closed:
+            return
+        default:
+    }
+    var policy = stream.policy
+    if (policy == OverflowDropOldest && cap(stream.events) == 0) {
+        policy = OverflowDropNewest
+    }
+    switch policy {
+        case OverflowDropOldest:
+            for {
+                select {
+                    case stream.events <- event:
+                        return
+                    default:
+                }
+                select {
+                    case <-stream.events:
+                    default:
+                }
+            }
+        case OverflowDropNewest:
+            select {
+                case stream.events <- event:
+                default:
+            }
+        default:
+            select {
+                case stream.events <- event:
+                case <-stream.closed:
+            }
+    }
+}
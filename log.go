@@ -0,0 +1,186 @@
+/**
+ * File        : log.go
+ * Copyright   : Copyright (c) 2015-2017 Mirror Labs, Inc. All rights reserved.
+ * License     : GPLv3
+ * Maintainer  : Enzo Haussecker <enzo@mirror.co>, Dominic Williams <dominic@string.technology>
+ * Stability   : Experimental
+ * Portability : Non-portable (requires Tox core at commit dcf2aaa or c-toxcore 0.2)
+ *
+ * This module routes the log records of the core to a Go logger, by default
+ * the one of the log/slog package. The core logs while it creates an instance,
+ * before the instance is registered, so loggers have handles of their own.
+ * Only c-toxcore 0.2 has a log callback. At commit dcf2aaa, the core does not
+ * log, and the logger only receives the records of the package itself.
+ */
+
+package tox
+
+//#include "log.h"
+import "C"
+import "context"
+import "log/slog"
+import "path/filepath"
+import "runtime"
+import "sync"
+import "unsafe"
+
+////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////// STRUCT TYPES /////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// This type holds the logger of a Tox instance along with the lowest level of
+// the log records it receives.
+type logSink struct {
+    logger ToxLogger
+    level  ToxLogLevel
+}
+
+// This type maps integer handles to loggers.
+type logRegistry struct {
+    lock  sync.RWMutex
+    sinks map[uintptr]*logSink
+    next  uintptr
+}
+
+// The registry of loggers of live Tox instances.
+var loggers = logRegistry{sinks: make(map[uintptr]*logSink)}
+
+// The level below the debug level at which trace records are logged.
+const slogLevelTrace = slog.LevelDebug - 4
+
+////////////////////////////////////////////////////////////////////////////////
+/////////////////////////////////// LOGGING ////////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Create a logger that writes the log records of the core to a structured
+// logger. The file, line and function of each record are attached as
+// attributes. Trace records are logged below the debug level. If the
+// structured logger is nil, then the default logger of the log/slog package is
+// used.
+func SlogLogger(logger *slog.Logger) ToxLogger {
+    return func(level ToxLogLevel, file string, line uint32, function string, message string) {
+        var target = logger
+        if (target == nil) {
+            target = slog.Default()
+        }
+        target.Log(
+            context.Background(),
+            level.slogLevel(),
+            message,
+            slog.String("file", file),
+            slog.Uint64("line", uint64(line)),
+            slog.String("function", function),
+        )
+    }
+}
+
+// Get the level of the log/slog package that corresponds to a log level.
+func (level ToxLogLevel) slogLevel() slog.Level {
+    switch level {
+        case ToxLogLevelTrace:
+            return slogLevelTrace
+        case ToxLogLevelDebug:
+            return slog.LevelDebug
+        case ToxLogLevelInfo:
+            return slog.LevelInfo
+        case ToxLogLevelWarning:
+            return slog.LevelWarn
+        default:
+            return slog.LevelError
+    }
+}
+
+// Create the log sink of a Tox instance from its startup options.
+func newLogSink(options *ToxOptions) *logSink {
+    var logger = options.Logger
+    if (logger == nil) {
+        logger = SlogLogger(nil)
+    }
+    return &logSink { logger: logger, level: options.LogLevel }
+}
+
+// Check whether a log record of the given level reaches the logger.
+func (sink *logSink) enabled(level ToxLogLevel) bool {
+    return level >= sink.level
+}
+
+// Log a record of the package itself, rather than of the core. The file and
+// line of the caller are attached to the record.
+func (sink *logSink) log(level ToxLogLevel, function string, message string) {
+    if (!sink.enabled(level)) {
+        return
+    }
+    _, file, line, _ := runtime.Caller(1)
+    sink.logger(level, filepath.Base(file), uint32(line), function, message)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+/////////////////////////////// HANDLE REGISTRY ////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Register a log sink and return its handle. Handles are never zero.
+func (registry *logRegistry) register(sink *logSink) uintptr {
+    registry.lock.Lock()
+    defer registry.lock.Unlock()
+    registry.next++
+    registry.sinks[registry.next] = sink
+    return registry.next
+}
+
+// Release the handle of a log sink.
+func (registry *logRegistry) unregister(id uintptr) {
+    registry.lock.Lock()
+    delete(registry.sinks, id)
+    registry.lock.Unlock()
+}
+
+// Get the log sink with the given handle. This returns nil if the sink has
+// been released.
+func (registry *logRegistry) get(id uintptr) *logSink {
+    registry.lock.RLock()
+    defer registry.lock.RUnlock()
+    return registry.sinks[id]
+}
+
+// Look up the log sink identified by the user data of the log callback. This
+// returns nil if the sink has been released.
+func (registry *logRegistry) lookup(c_user_data unsafe.Pointer) *logSink {
+    return registry.get(uintptr(c_user_data))
+}
+
+////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////// CALLBACK HOOK ////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+//export callback_log
+func callback_log(
+    c_tox *C.Tox,
+    c_level C.TOX_LOG_LEVEL,
+    c_file *C.char,
+    c_line C.uint32_t,
+    c_function *C.char,
+    c_message *C.char,
+    c_user_data unsafe.Pointer,
+) {
+    sink := loggers.lookup(c_user_data)
+    if (sink == nil) {
+        return
+    }
+    var level ToxLogLevel
+    switch c_level {
+        case C.TOX_LOG_LEVEL_TRACE:
+            level = ToxLogLevelTrace
+        case C.TOX_LOG_LEVEL_DEBUG:
+            level = ToxLogLevelDebug
+        case C.TOX_LOG_LEVEL_INFO:
+            level = ToxLogLevelInfo
+        case C.TOX_LOG_LEVEL_WARNING:
+            level = ToxLogLevelWarning
+        default:
+            level = ToxLogLevelError
+    }
+    if (!sink.enabled(level)) {
+        return
+    }
+    sink.logger(level, C.GoString(c_file), uint32(c_line), C.GoString(c_function), C.GoString(c_message))
+}
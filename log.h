@@ -0,0 +1,30 @@
+/**
+ * File        : log.h
+ * Copyright   : Copyright (c) 2015-2017 Mirror Labs, Inc. All rights reserved.
+ * License     : GPLv3
+ * Maintainer  : Enzo Haussecker <enzo@mirror.co>, Dominic Williams <dominic@string.technology>
+ * Stability   : Experimental
+ * Portability : Non-portable (requires Tox core at commit dcf2aaa or c-toxcore 0.2)
+ *
+ * This header defines the log levels that the hook in log.go receives. Commit
+ * dcf2aaa has no log callback, so the log levels of c-toxcore 0.2 are defined
+ * here, which lets the hook compile with both versions.
+ */
+
+#ifndef LOG_H
+#define LOG_H
+
+#include <stdint.h>
+#include <tox/tox.h>
+
+#ifndef TOXCORE_02
+typedef enum TOX_LOG_LEVEL {
+    TOX_LOG_LEVEL_TRACE,
+    TOX_LOG_LEVEL_DEBUG,
+    TOX_LOG_LEVEL_INFO,
+    TOX_LOG_LEVEL_WARNING,
+    TOX_LOG_LEVEL_ERROR,
+} TOX_LOG_LEVEL;
+#endif
+
+#endif
@@ -0,0 +1,484 @@
+/**
+ * File        : outbox.go
+ * Copyright   : Copyright (c) 2015-2017 Mirror Labs, Inc. All rights reserved.
+ * License     : GPLv3
+ * Maintainer  : Enzo Haussecker <enzo@mirror.co>, Dominic Williams <dominic@string.technology>
+ * Stability   : Experimental
+ * Portability : Non-portable (requires Tox core at commit dcf2aaa)
+ *
+ * This module provides a persistent outbox for chat messages. Messages to
+ * friends that are offline are stored, sent in order once the friend comes
+ * online, and removed only after the friend sends a read receipt. The storage
+ * backend is pluggable.
+ */
+
+package tox
+
+//#include "callbacks.h"
+import "C"
+import "encoding/hex"
+import "encoding/json"
+import "errors"
+import "io/ioutil"
+import "os"
+import "sync"
+import "time"
+
+////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////// STRUCT TYPES /////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// This type represents a chat message held in an outbox. Messages are ordered
+// by their sequence number, and friends are identified by their public key so
+// that messages remain meaningful across restarts.
+type OutboxMessage struct {
+
+    Sequence    uint64
+    PublicKey   ToxPublicKey
+    MessageType ToxMessageType
+    Message     []byte
+
+}
+
+// This type represents a persistent store of outbox messages. A store may be
+// backed by a file, an embedded key-value store, or anything else that can
+// hold messages keyed by their sequence number.
+type OutboxStore interface {
+
+    // Load all stored messages, ordered by sequence number.
+    LoadMessages() (messages []*OutboxMessage, throw error)
+
+    // Store a message.
+    SaveMessage(message *OutboxMessage) (throw error)
+
+    // Remove the message with the given sequence number.
+    DeleteMessage(sequence uint64) (throw error)
+
+}
+
+// This type represents a persistent outbox of chat messages for a Tox
+// instance. Messages are delivered at least once: a message whose read receipt
+// was lost with the connection is sent again.
+type Outbox struct {
+
+    send      func(friendNumber uint32, messageType ToxMessageType, message []byte) (uint32, error)
+    publicKey func(friendNumber uint32) (ToxPublicKey, error)
+    store     OutboxStore
+    lock      sync.Mutex
+    messages  []*outboxEntry
+    inflight  map[deliveryKey]*outboxEntry
+    retrying  map[uint32]bool
+    flushing  map[uint32]bool
+    receipts  map[deliveryKey]bool
+    next      uint64
+    onError   OnOutboxError
+
+}
+
+// This type represents a function that executes when an outbox fails to update
+// its store in the background, for example because a delivered message cannot
+// be deleted. Such a message is sent again after a restart.
+type OnOutboxError func(outbox *Outbox, throw error)
+
+// This type holds an outbox message along with its delivery progress. A
+// message is in flight once it has been handed to the core and is waiting for
+// a read receipt. While it is being handed to the core, the message is marked
+// as sending, and as lost if the connection to the friend is lost meanwhile.
+type outboxEntry struct {
+
+    message      *OutboxMessage
+    inflight     bool
+    sending      bool
+    lost         bool
+    friendNumber uint32
+    messageId    uint32
+
+}
+
+// This type represents an outbox store backed by a single JSON file.
+type FileOutboxStore struct {
+
+    path string
+    lock sync.Mutex
+
+}
+
+// This type represents the on-disk encoding of an outbox message.
+type outboxRecord struct {
+
+    Sequence    uint64         `json:"sequence"`
+    PublicKey   string         `json:"public_key"`
+    MessageType ToxMessageType `json:"message_type"`
+    Message     []byte         `json:"message"`
+
+}
+
+////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////// OUTBOX STORE /////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Create an outbox store backed by the file at the given path. The file is
+// created on the first write.
+func NewFileOutboxStore(path string) *FileOutboxStore {
+    return &FileOutboxStore { path: path }
+}
+
+// Load all stored messages, ordered by sequence number.
+func (store *FileOutboxStore) LoadMessages() (messages []*OutboxMessage, throw error) {
+    store.lock.Lock()
+    defer store.lock.Unlock()
+    records, throw := store.read()
+    if throw != nil {
+        return
+    }
+    for _, record := range records {
+        var message = &OutboxMessage {
+            Sequence: record.Sequence,
+            MessageType: record.MessageType,
+            Message: record.Message,
+        }
+        throw = decodeHex(message.PublicKey[:], record.PublicKey)
+        if throw != nil {
+            return nil, throw
+        }
+        messages = append(messages, message)
+    }
+    return
+}
+
+// Store a message.
+func (store *FileOutboxStore) SaveMessage(message *OutboxMessage) (throw error) {
+    store.lock.Lock()
+    defer store.lock.Unlock()
+    records, throw := store.read()
+    if throw != nil {
+        return
+    }
+    var record = &outboxRecord {
+        Sequence: message.Sequence,
+        PublicKey: hex.EncodeToString(message.PublicKey[:]),
+        MessageType: message.MessageType,
+        Message: message.Message,
+    }
+    var i = len(records)
+    for (i > 0 && records[i-1].Sequence > message.Sequence) {
+        i--
+    }
+    records = append(records, nil)
+    copy(records[i+1:], records[i:])
+    records[i] = record
+    return store.write(records)
+}
+
+// Remove the message with the given sequence number.
+func (store *FileOutboxStore) DeleteMessage(sequence uint64) (throw error) {
+    store.lock.Lock()
+    defer store.lock.Unlock()
+    records, throw := store.read()
+    if throw != nil {
+        return
+    }
+    for i, record := range records {
+        if (record.Sequence == sequence) {
+            records = append(records[:i], records[i+1:]...)
+            break
+        }
+    }
+    return store.write(records)
+}
+
+// Read all records from the backing file. A missing file holds no records.
+func (store *FileOutboxStore) read() (records []*outboxRecord, throw error) {
+    data, throw := ioutil.ReadFile(store.path)
+    if os.IsNotExist(throw) {
+        return nil, nil
+    }
+    if throw != nil {
+        return nil, throw
+    }
+    throw = json.Unmarshal(data, &records)
+    return
+}
+
+// Write all records to the backing file.
+func (store *FileOutboxStore) write(records []*outboxRecord) (throw error) {
+    data, throw := json.Marshal(records)
+    if throw != nil {
+        return
+    }
+    return writeFileAtomic(store.path, data)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+//////////////////////////////////// OUTBOX ////////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Create a persistent outbox for a Tox instance. Messages left in the store by
+// a previous run are sent once the friends involved come online. Only one
+// outbox can be associated with a Tox instance.
+func NewOutbox(tox *Tox, store OutboxStore) (outbox *Outbox, throw error) {
+    messages, throw := store.LoadMessages()
+    if throw != nil {
+        return
+    }
+    outbox = &Outbox {
+        send: tox.view.FriendSendMessage,
+        publicKey: tox.view.FriendGetPublicKey,
+        store: store,
+        inflight: make(map[deliveryKey]*outboxEntry),
+        retrying: make(map[uint32]bool),
+        flushing: make(map[uint32]bool),
+        receipts: make(map[deliveryKey]bool),
+    }
+    for _, message := range messages {
+        outbox.messages = append(outbox.messages, &outboxEntry{message: message})
+        if (message.Sequence >= outbox.next) {
+            outbox.next = message.Sequence + 1
+        }
+    }
+    tox.outboxLock.Lock()
+    tox.outbox = outbox
+    tox.outboxLock.Unlock()
+    tox.exec(nil, func() {
+        C.register_friend_read_receipt(tox.handle, C.uintptr_t(tox.id))
+        C.register_friend_connection_status(tox.handle, C.uintptr_t(tox.id))
+    })
+    return
+}
+
+// Queue a chat message for a friend. The message is stored before this
+// returns, and is sent immediately if the friend is online.
+func (outbox *Outbox) Send(friendNumber uint32, messageType ToxMessageType, message []byte) (throw error) {
+    if (len(message) == 0) {
+        return ToxErrFriendSendMessageEmpty
+    }
+    if (len(message) > ToxMaxMessageLength) {
+        return ToxErrFriendSendMessageTooLong
+    }
+    publicKey, throw := outbox.publicKey(friendNumber)
+    if throw != nil {
+        return
+    }
+    outbox.lock.Lock()
+    var entry = &outboxEntry {
+        message: &OutboxMessage {
+            Sequence: outbox.next,
+            PublicKey: publicKey,
+            MessageType: messageType,
+            Message: append([]byte(nil), message...),
+        },
+    }
+    throw = outbox.store.SaveMessage(entry.message)
+    if throw != nil {
+        outbox.lock.Unlock()
+        return
+    }
+    outbox.next++
+    outbox.messages = append(outbox.messages, entry)
+    outbox.lock.Unlock()
+    outbox.flush(friendNumber)
+    return
+}
+
+// Get the messages waiting to be delivered to a friend, in the order they will
+// be sent.
+func (outbox *Outbox) Pending(friendNumber uint32) (messages []OutboxMessage, throw error) {
+    publicKey, throw := outbox.publicKey(friendNumber)
+    if throw != nil {
+        return
+    }
+    outbox.lock.Lock()
+    defer outbox.lock.Unlock()
+    for _, entry := range outbox.messages {
+        if (entry.message.PublicKey == publicKey) {
+            messages = append(messages, *entry.message)
+        }
+    }
+    return
+}
+
+// Register a function that executes when the outbox fails to update its store
+// in the background.
+func (outbox *Outbox) SetOnError(callback OnOutboxError) {
+    outbox.lock.Lock()
+    outbox.onError = callback
+    outbox.lock.Unlock()
+}
+
+// Send all queued messages to a friend, in order. Sending stops at the first
+// message that the core does not accept, and resumes on the next flush. If the
+// send queue of the core is full, then the next flush happens by itself once
+// the queue has had time to drain. Only one goroutine sends to a friend at a
+// time, and a flush requested meanwhile is carried out by that goroutine. The
+// outbox lock is released while a message is handed to the core, since the
+// handlers of the instance take it, so a read receipt that arrives before the
+// message is in flight is recorded until the flush ends.
+func (outbox *Outbox) flush(friendNumber uint32) {
+    publicKey, err := outbox.publicKey(friendNumber)
+    if err != nil {
+        return
+    }
+    outbox.lock.Lock()
+    if _, busy := outbox.flushing[friendNumber]; busy {
+        outbox.flushing[friendNumber] = true
+        outbox.lock.Unlock()
+        return
+    }
+    outbox.flushing[friendNumber] = false
+    var failures []error
+    for {
+        var entry = outbox.queued(publicKey)
+        if (entry == nil) {
+            if (!outbox.flushing[friendNumber]) {
+                break
+            }
+            outbox.flushing[friendNumber] = false
+            continue
+        }
+        entry.inflight = true
+        entry.sending = true
+        entry.friendNumber = friendNumber
+        outbox.lock.Unlock()
+        messageId, err := outbox.send(friendNumber, entry.message.MessageType, entry.message.Message)
+        outbox.lock.Lock()
+        entry.sending = false
+        if (err != nil || entry.lost) {
+            entry.inflight = false
+            entry.lost = false
+            if (errors.Is(err, ToxErrFriendSendMessageSendQ)) {
+                outbox.retry(friendNumber)
+            }
+            if (!outbox.flushing[friendNumber]) {
+                break
+            }
+            outbox.flushing[friendNumber] = false
+            continue
+        }
+        var key = deliveryKey{friendNumber, messageId}
+        if (outbox.receipts[key]) {
+            delete(outbox.receipts, key)
+            if throw := outbox.remove(entry); throw != nil {
+                failures = append(failures, throw)
+            }
+            continue
+        }
+        entry.messageId = messageId
+        outbox.inflight[key] = entry
+    }
+    delete(outbox.flushing, friendNumber)
+    for key := range outbox.receipts {
+        if (key.friendNumber == friendNumber) {
+            delete(outbox.receipts, key)
+        }
+    }
+    var callback = outbox.onError
+    outbox.lock.Unlock()
+    if (callback != nil) {
+        for _, throw := range failures {
+            callback(outbox, throw)
+        }
+    }
+}
+
+// Get the first queued message to a friend that is not in flight. The caller
+// must hold the outbox lock.
+func (outbox *Outbox) queued(publicKey ToxPublicKey) *outboxEntry {
+    for _, entry := range outbox.messages {
+        if (entry.message.PublicKey == publicKey && !entry.inflight) {
+            return entry
+        }
+    }
+    return nil
+}
+
+// Remove a delivered message from the outbox and from its store. The caller
+// must hold the outbox lock.
+func (outbox *Outbox) remove(entry *outboxEntry) (throw error) {
+    for i := range outbox.messages {
+        if (outbox.messages[i] == entry) {
+            outbox.messages = append(outbox.messages[:i], outbox.messages[i+1:]...)
+            break
+        }
+    }
+    return outbox.store.DeleteMessage(entry.message.Sequence)
+}
+
+// Flush the queued messages to a friend again after the send retry interval.
+// Only one retry per friend is scheduled at a time. The caller must hold the
+// outbox lock.
+func (outbox *Outbox) retry(friendNumber uint32) {
+    if (outbox.retrying[friendNumber]) {
+        return
+    }
+    outbox.retrying[friendNumber] = true
+    time.AfterFunc(sendRetryInterval, func() {
+        outbox.lock.Lock()
+        delete(outbox.retrying, friendNumber)
+        outbox.lock.Unlock()
+        outbox.flush(friendNumber)
+    })
+}
+
+////////////////////////////////////////////////////////////////////////////////
+/////////////////////////////// EVENT PROCESSING ///////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Handle a read receipt from a friend. The confirmed message is removed from
+// the outbox, and the next queued messages are sent. If the message cannot be
+// deleted from the store, then the error is passed to the error callback of the
+// outbox.
+func (tox *Tox) handleOutboxReadReceipt(friendNumber uint32, messageId uint32) {
+    tox.outboxLock.Lock()
+    var outbox = tox.outbox
+    tox.outboxLock.Unlock()
+    if (outbox == nil) {
+        return
+    }
+    var key = deliveryKey{friendNumber, messageId}
+    outbox.lock.Lock()
+    var entry = outbox.inflight[key]
+    if (entry == nil) {
+        if _, busy := outbox.flushing[friendNumber]; busy {
+            outbox.receipts[key] = true
+        }
+        outbox.lock.Unlock()
+        return
+    }
+    delete(outbox.inflight, key)
+    var throw = outbox.remove(entry)
+    var callback = outbox.onError
+    outbox.lock.Unlock()
+    if (throw != nil && callback != nil) {
+        callback(outbox, throw)
+    }
+    outbox.flush(friendNumber)
+}
+
+// Handle a change in the connection status of a friend. Messages in flight are
+// discarded by the core when the connection is lost, so they are queued again.
+// Queued messages are sent once the friend is back online.
+func (tox *Tox) handleOutboxConnectionStatus(friendNumber uint32, connectionStatus ToxConnectionStatus) {
+    tox.outboxLock.Lock()
+    var outbox = tox.outbox
+    tox.outboxLock.Unlock()
+    if (outbox == nil) {
+        return
+    }
+    if (connectionStatus != ToxConnectionNone) {
+        outbox.flush(friendNumber)
+        return
+    }
+    outbox.lock.Lock()
+    defer outbox.lock.Unlock()
+    for key, entry := range outbox.inflight {
+        if (key.friendNumber == friendNumber) {
+            entry.inflight = false
+            delete(outbox.inflight, key)
+        }
+    }
+    for _, entry := range outbox.messages {
+        if (entry.sending && entry.friendNumber == friendNumber) {
+            entry.lost = true
+        }
+    }
+}
@@ -0,0 +1,164 @@
+/**
+ * File        : owner.go
+ * Copyright   : Copyright (c) 2015-2017 Mirror Labs, Inc. All rights reserved.
+ * License     : GPLv3
+ * Maintainer  : Enzo Haussecker <enzo@mirror.co>, Dominic Williams <dominic@string.technology>
+ * Stability   : Experimental
+ * Portability : Non-portable (requires Tox core at commit dcf2aaa)
+ *
+ * By default, API calls take the lock of the instance and call into the core
+ * from the calling goroutine. If the DedicatedThread option is set, then a
+ * single goroutine locked to an OS thread owns the core instead. It iterates
+ * the core at the requested interval and executes every API call, in the order
+ * in which the calls are submitted. The callbacks also run on the owner, after
+ * each iteration and before any further call is served, so they observe the
+ * events in order with the calls. API calls that the callbacks make are
+ * recognized by the OS thread they come from, and run immediately.
+ */
+
+package tox
+
+//#include <pthread.h>
+//#include "compat.h"
+import "C"
+import "runtime"
+import "time"
+
+////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////// STRUCT TYPES /////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// This type holds the state of the goroutine that owns the core.
+type owner struct {
+    calls  chan func()
+    thread C.pthread_t
+}
+
+////////////////////////////////////////////////////////////////////////////////
+/////////////////////////////////// EXECUTION //////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Run a function with exclusive access to the core. If the instance has a
+// dedicated thread, then the function runs on it, otherwise it runs on the
+// calling goroutine while holding the lock of the instance. A function passed
+// by the dedicated thread itself, for example from a callback, runs right
+// away. If the instance has been destroyed, then the function does not run,
+// and ToxErrClosed is stored in the given error unless it is nil.
+func (tox *Tox) exec(throw *error, function func()) {
+    if (tox.owner != nil && tox.owner.current()) {
+        if (tox.closed()) {
+            if (throw != nil) {
+                *throw = ToxErrClosed
+            }
+            return
+        }
+        function()
+        return
+    }
+    if (tox.owner == nil) {
+        tox.lock.Lock()
+        defer tox.lock.Unlock()
+        if (tox.closed()) {
+            if (throw != nil) {
+                *throw = ToxErrClosed
+            }
+            return
+        }
+        function()
+        return
+    }
+    var reply = make(chan struct{})
+    var call = func() {
+        defer close(reply)
+        function()
+    }
+    select {
+        case tox.owner.calls <- call:
+            <-reply
+        case <-tox.run.done:
+            if (throw != nil) {
+                *throw = ToxErrClosed
+            }
+    }
+}
+
+// Check whether the instance has been destroyed.
+func (tox *Tox) closed() bool {
+    select {
+        case <-tox.run.done:
+            return true
+        default:
+            return false
+    }
+}
+
+// Check whether the calling goroutine is the owner. The owner is locked to its
+// OS thread, so no other goroutine runs on that thread.
+func (owner *owner) current() bool {
+    return C.pthread_equal(C.pthread_self(), owner.thread) != 0
+}
+
+// Start the goroutine of a dedicated thread, and wait until it has taken its
+// OS thread.
+func (tox *Tox) startOwner() {
+    tox.owner = &owner {
+        calls: make(chan func()),
+    }
+    var started = make(chan struct{})
+    go tox.view.own(started)
+    <-started
+}
+
+// Own the core. This serves API calls, iterates the core and runs the
+// callbacks until the instance is destroyed.
+func (tox *Tox) own(started chan struct{}) {
+    runtime.LockOSThread()
+    defer runtime.UnlockOSThread()
+    tox.owner.thread = C.pthread_self()
+    close(started)
+    var timer = time.NewTimer(0)
+    defer timer.Stop()
+    for {
+        if (tox.closed()) {
+            return
+        }
+        select {
+            case <-tox.run.done:
+                return
+            case call := <-tox.owner.calls:
+                call()
+            case <-timer.C:
+                timer.Reset(tox.iterate())
+            case <-tox.run.wake:
+                if (!timer.Stop()) {
+                    select {
+                        case <-timer.C:
+                        default:
+                    }
+                }
+                timer.Reset(tox.iterate())
+        }
+    }
+}
+
+// Iterate the core and run the queued handlers. This must only be called by
+// the owner. This returns the time until the next iteration.
+func (tox *Tox) iterate() time.Duration {
+    var interval = time.Duration(uint32(C.tox_iteration_interval(tox.handle))) * time.Millisecond
+    var start = time.Now()
+    C.iterate(tox.handle, C.uintptr_t(tox.id))
+    var pending = tox.pending
+    tox.pending = nil
+    for _, handler := range pending {
+        if (tox.closed()) {
+            return 0
+        }
+        handler()
+    }
+    var elapsed = time.Since(start)
+    tox.run.record(interval, elapsed)
+    if (elapsed < interval) {
+        return interval - elapsed
+    }
+    return 0
+}
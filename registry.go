@@ -0,0 +1,63 @@
+/**
+ * File        : registry.go
+ * Copyright   : Copyright (c) 2015-2017 Mirror Labs, Inc. All rights reserved.
+ * License     : GPLv3
+ * Maintainer  : Enzo Haussecker <enzo@mirror.co>, Dominic Williams <dominic@string.technology>
+ * Stability   : Experimental
+ * Portability : Non-portable (requires Tox core at commit dcf2aaa)
+ *
+ * The core retains the user data passed along with each callback, so it cannot
+ * be a Go pointer. Instead, every Tox instance is assigned an integer handle
+ * when it is created, and the callback hooks use that handle to look up the
+ * instance in this registry. The handle is released when the instance is
+ * destroyed. The registry holds weak pointers, so that an instance that is
+ * leaked without being destroyed can still be garbage collected.
+ */
+
+package tox
+
+import "sync"
+import "unsafe"
+import "weak"
+
+////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////// STRUCT TYPES /////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// This type maps integer handles to Tox instances.
+type registry struct {
+    lock      sync.RWMutex
+    instances map[uintptr]weak.Pointer[Tox]
+    next      uintptr
+}
+
+// The registry of live Tox instances.
+var instances = registry{instances: make(map[uintptr]weak.Pointer[Tox])}
+
+////////////////////////////////////////////////////////////////////////////////
+/////////////////////////////// HANDLE REGISTRY ////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Register a Tox instance and return its handle. Handles are never zero.
+func (registry *registry) register(tox *Tox) uintptr {
+    registry.lock.Lock()
+    defer registry.lock.Unlock()
+    registry.next++
+    registry.instances[registry.next] = weak.Make(tox)
+    return registry.next
+}
+
+// Release the handle of a Tox instance.
+func (registry *registry) unregister(id uintptr) {
+    registry.lock.Lock()
+    delete(registry.instances, id)
+    registry.lock.Unlock()
+}
+
+// Look up the Tox instance identified by the user data of a callback. This
+// returns nil if the instance has been destroyed or garbage collected.
+func (registry *registry) lookup(c_user_data unsafe.Pointer) *Tox {
+    registry.lock.RLock()
+    defer registry.lock.RUnlock()
+    return registry.instances[uintptr(c_user_data)].Value()
+}
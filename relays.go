@@ -0,0 +1,132 @@
+/**
+ * File        : relays.go
+ * Copyright   : Copyright (c) 2015-2017 Mirror Labs, Inc. All rights reserved.
+ * License     : GPLv3
+ * Maintainer  : Enzo Haussecker <enzo@mirror.co>, Dominic Williams <dominic@string.technology>
+ * Stability   : Experimental
+ * Portability : Non-portable (requires Tox core at commit dcf2aaa)
+ *
+ * This module manages the TCP relays of a Tox instance. Clients that cannot
+ * use UDP reach the network only through TCP relays, so a list of relays is
+ * configured once and added to the core again whenever the connection to the
+ * network is lost.
+ */
+
+package tox
+
+//#include "callbacks.h"
+import "C"
+import "sync"
+
+////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////// STRUCT TYPES /////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// This type represents a list of TCP relays for a Tox instance. The relays are
+// added when the list is created, and again each time the instance loses its
+// connection to the network.
+type TCPRelays struct {
+
+    add   func(*SeedNode) error
+    lock  sync.Mutex
+    nodes []SeedNode
+
+}
+
+////////////////////////////////////////////////////////////////////////////////
+////////////////////////////////// TCP RELAYS //////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Create a list of TCP relays for a Tox instance and add the relays to the
+// core. If a relay cannot be added, for example because its host name does not
+// resolve, then the first such error is returned along with the list, and the
+// relay is tried again on the next reconnect. Only one list of relays can be
+// associated with a Tox instance.
+func NewTCPRelays(tox *Tox, seedNodes ...*SeedNode) (relays *TCPRelays, throw error) {
+    relays = &TCPRelays { add: tox.view.AddTCPRelay }
+    for _, seedNode := range seedNodes {
+        _, throw = seedNode.decodePublicKey()
+        if throw != nil {
+            return nil, throw
+        }
+        relays.nodes = append(relays.nodes, *seedNode)
+    }
+    tox.relaysLock.Lock()
+    tox.relays = relays
+    tox.relaysLock.Unlock()
+    tox.exec(nil, func() {
+        C.register_self_connection_status(tox.handle, C.uintptr_t(tox.id))
+    })
+    throw = relays.Apply()
+    return
+}
+
+// Add a TCP relay to the list and to the core. A relay with the same host and
+// port is replaced. The relay stays in the list even if the core rejects it.
+func (relays *TCPRelays) Add(seedNode *SeedNode) (throw error) {
+    _, throw = seedNode.decodePublicKey()
+    if throw != nil {
+        return
+    }
+    relays.lock.Lock()
+    relays.remove(seedNode.Host, seedNode.Port)
+    relays.nodes = append(relays.nodes, *seedNode)
+    relays.lock.Unlock()
+    return relays.add(seedNode)
+}
+
+// Remove a TCP relay from the list. The core has no way to forget a relay, so
+// it remains in use until the next reconnect. This returns false if the relay
+// is not in the list.
+func (relays *TCPRelays) Remove(host string, port uint16) (ok bool) {
+    relays.lock.Lock()
+    defer relays.lock.Unlock()
+    return relays.remove(host, port)
+}
+
+// Get the TCP relays in the list, in the order in which they are added.
+func (relays *TCPRelays) Nodes() (seedNodes []SeedNode) {
+    relays.lock.Lock()
+    defer relays.lock.Unlock()
+    return append([]SeedNode(nil), relays.nodes...)
+}
+
+// Add all TCP relays in the list to the core. Every relay is tried, and the
+// first error is returned.
+func (relays *TCPRelays) Apply() (throw error) {
+    for _, seedNode := range relays.Nodes() {
+        var err = relays.add(&seedNode)
+        if (err != nil && throw == nil) {
+            throw = err
+        }
+    }
+    return
+}
+
+// Remove a TCP relay from the list. The caller must hold the list lock.
+func (relays *TCPRelays) remove(host string, port uint16) (ok bool) {
+    for i, seedNode := range relays.nodes {
+        if (seedNode.Host == host && seedNode.Port == port) {
+            relays.nodes = append(relays.nodes[:i], relays.nodes[i + 1:]...)
+            return true
+        }
+    }
+    return false
+}
+
+////////////////////////////////////////////////////////////////////////////////
+/////////////////////////////// EVENT PROCESSING ///////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Handle a change in the connection status of the client. The TCP relays are
+// added again when the connection to the network is lost, so that the core can
+// use them to reconnect.
+func (tox *Tox) handleRelaysConnectionStatus(connectionStatus ToxConnectionStatus) {
+    tox.relaysLock.Lock()
+    var relays = tox.relays
+    tox.relaysLock.Unlock()
+    if (relays == nil || connectionStatus != ToxConnectionNone) {
+        return
+    }
+    relays.Apply()
+}
@@ -0,0 +1,551 @@
+/**
+ * File        : resume.go
+ * Copyright   : Copyright (c) 2015-2017 Mirror Labs, Inc. All rights reserved.
+ * License     : GPLv3
+ * Maintainer  : Enzo Haussecker <enzo@mirror.co>, Dominic Williams <dominic@string.technology>
+ * Stability   : Experimental
+ * Portability : Non-portable (requires Tox core at commit dcf2aaa)
+ *
+ * This module provides resumable file transfers. The state of each transfer is
+ * persisted under its file identifier, so that when a friend reconnects, or the
+ * client is restarted from its serialized save data, the sender can offer the
+ * file again and the receiver can seek to the last confirmed offset.
+ */
+
+package tox
+
+import "crypto/rand"
+import "encoding/hex"
+import "encoding/json"
+import "errors"
+import "io"
+import "io/ioutil"
+import "os"
+import "sync"
+
+////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////// STRUCT TYPES /////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// This type represents the persisted state of a resumable file transfer. Friends
+// are identified by their public key rather than their friend number, so that
+// the state remains meaningful across restarts.
+type TransferState struct {
+
+    FileId    ToxFileId
+    PublicKey ToxPublicKey
+    Outgoing  bool
+    Filename  []byte
+    Size      uint64
+    Offset    uint64
+
+}
+
+// This type represents a persistent store of resumable file transfer states.
+type TransferStore interface {
+
+    // Load all stored transfer states.
+    LoadTransfers() (states []*TransferState, throw error)
+
+    // Store a transfer state, replacing any state with the same file identifier.
+    SaveTransfer(state *TransferState) (throw error)
+
+    // Remove the transfer state with the given file identifier.
+    DeleteTransfer(fileId ToxFileId) (throw error)
+
+}
+
+// This type represents a function that reopens the source of an outgoing file
+// transfer after a restart.
+type TransferSource func(state *TransferState) (io.ReaderAt, error)
+
+// This type represents a function that reopens the destination of an incoming
+// file transfer after a restart. Data already received must be preserved.
+type TransferSink func(state *TransferState) (io.WriterAt, error)
+
+// This type represents a manager of resumable file transfers for a Tox
+// instance.
+type ResumableTransfers struct {
+
+    tox       *Tox
+    store     TransferStore
+    source    TransferSource
+    sink      TransferSink
+    lock      sync.Mutex
+    states    map[ToxFileId]*TransferState
+    saved     map[ToxFileId]uint64
+    active    map[ToxFileId]*FileTransfer
+    readers   map[ToxFileId]io.ReaderAt
+    writers   map[ToxFileId]io.WriterAt
+
+}
+
+// This type represents a transfer store backed by a single JSON file. It is
+// intended to be kept alongside the serialized save data of the Tox instance.
+type FileTransferStore struct {
+
+    path string
+    lock sync.Mutex
+
+}
+
+// This type represents the on-disk encoding of a transfer state.
+type transferRecord struct {
+
+    FileId    string `json:"file_id"`
+    PublicKey string `json:"public_key"`
+    Outgoing  bool   `json:"outgoing"`
+    Filename  []byte `json:"filename"`
+    Size      uint64 `json:"size"`
+    Offset    uint64 `json:"offset"`
+
+}
+
+// This type tracks the offset written to the destination of an incoming file
+// transfer.
+type resumeWriter struct {
+
+    manager *ResumableTransfers
+    fileId  ToxFileId
+    writer  io.WriterAt
+
+}
+
+// The minimum number of bytes received between two persisted offsets of an
+// incoming file transfer. The offset is also persisted whenever the transfer
+// breaks.
+const resumeSaveInterval = 1 << 16
+
+////////////////////////////////////////////////////////////////////////////////
+//////////////////////////////// TRANSFER STORE ////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Create a transfer store backed by the file at the given path. The file is
+// created on the first write.
+func NewFileTransferStore(path string) *FileTransferStore {
+    return &FileTransferStore { path: path }
+}
+
+// Load all stored transfer states.
+func (store *FileTransferStore) LoadTransfers() (states []*TransferState, throw error) {
+    store.lock.Lock()
+    defer store.lock.Unlock()
+    records, throw := store.read()
+    if throw != nil {
+        return
+    }
+    for _, record := range records {
+        var state = &TransferState {
+            Outgoing: record.Outgoing,
+            Filename: record.Filename,
+            Size: record.Size,
+            Offset: record.Offset,
+        }
+        throw = decodeHex(state.FileId[:], record.FileId)
+        if throw != nil {
+            return nil, throw
+        }
+        throw = decodeHex(state.PublicKey[:], record.PublicKey)
+        if throw != nil {
+            return nil, throw
+        }
+        states = append(states, state)
+    }
+    return
+}
+
+// Store a transfer state, replacing any state with the same file identifier.
+func (store *FileTransferStore) SaveTransfer(state *TransferState) (throw error) {
+    store.lock.Lock()
+    defer store.lock.Unlock()
+    records, throw := store.read()
+    if throw != nil {
+        return
+    }
+    records[hex.EncodeToString(state.FileId[:])] = &transferRecord {
+        FileId: hex.EncodeToString(state.FileId[:]),
+        PublicKey: hex.EncodeToString(state.PublicKey[:]),
+        Outgoing: state.Outgoing,
+        Filename: state.Filename,
+        Size: state.Size,
+        Offset: state.Offset,
+    }
+    return store.write(records)
+}
+
+// Remove the transfer state with the given file identifier.
+func (store *FileTransferStore) DeleteTransfer(fileId ToxFileId) (throw error) {
+    store.lock.Lock()
+    defer store.lock.Unlock()
+    records, throw := store.read()
+    if throw != nil {
+        return
+    }
+    delete(records, hex.EncodeToString(fileId[:]))
+    return store.write(records)
+}
+
+// Read all records from the backing file. A missing file holds no records.
+func (store *FileTransferStore) read() (records map[string]*transferRecord, throw error) {
+    records = make(map[string]*transferRecord)
+    data, throw := ioutil.ReadFile(store.path)
+    if os.IsNotExist(throw) {
+        return records, nil
+    }
+    if throw != nil {
+        return nil, throw
+    }
+    throw = json.Unmarshal(data, &records)
+    return
+}
+
+// Write all records to the backing file.
+func (store *FileTransferStore) write(records map[string]*transferRecord) (throw error) {
+    data, throw := json.Marshal(records)
+    if throw != nil {
+        return
+    }
+    return writeFileAtomic(store.path, data)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+///////////////////////////// RESUMABLE TRANSFERS //////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Create a manager of resumable file transfers for a Tox instance. Transfer
+// states left in the store by a previous run are resumed once the friends
+// involved come online. The source and sink are used to reopen the data of
+// those transfers, and may be nil if only transfers started in this run are to
+// be resumed. Only one manager can be associated with a Tox instance.
+func NewResumableTransfers(tox *Tox, store TransferStore, source TransferSource, sink TransferSink) (manager *ResumableTransfers, throw error) {
+    states, throw := store.LoadTransfers()
+    if throw != nil {
+        return
+    }
+    manager = &ResumableTransfers {
+        tox: tox.view,
+        store: store,
+        source: source,
+        sink: sink,
+        states: make(map[ToxFileId]*TransferState),
+        saved: make(map[ToxFileId]uint64),
+        active: make(map[ToxFileId]*FileTransfer),
+        readers: make(map[ToxFileId]io.ReaderAt),
+        writers: make(map[ToxFileId]io.WriterAt),
+    }
+    for _, state := range states {
+        manager.states[state.FileId] = state
+        manager.saved[state.FileId] = state.Offset
+    }
+    tox.registerFileTransferCallbacks()
+    tox.transfersLock.Lock()
+    tox.resumable = manager
+    tox.transfersLock.Unlock()
+    return
+}
+
+// Send a file to a friend as a resumable transfer. The returned file identifier
+// names the transfer across reconnections and restarts. If the friend is not
+// currently online, then no transfer is returned, and the file is offered once
+// the friend comes online.
+func (manager *ResumableTransfers) Send(friendNumber uint32, filename []byte, reader io.ReaderAt, size uint64) (fileId ToxFileId, transfer *FileTransfer, throw error) {
+    publicKey, throw := manager.tox.FriendGetPublicKey(friendNumber)
+    if throw != nil {
+        return
+    }
+    _, throw = rand.Read(fileId[:])
+    if throw != nil {
+        return
+    }
+    var state = &TransferState {
+        FileId: fileId,
+        PublicKey: publicKey,
+        Outgoing: true,
+        Filename: filename,
+        Size: size,
+    }
+    throw = manager.store.SaveTransfer(state)
+    if throw != nil {
+        return
+    }
+    manager.lock.Lock()
+    manager.states[fileId] = state
+    manager.saved[fileId] = 0
+    manager.readers[fileId] = reader
+    manager.lock.Unlock()
+    transfer, throw = manager.tox.sendFile(friendNumber, &fileId, filename, reader, size)
+    if (errors.Is(throw, ToxErrFileSendFriendNotConnected)) {
+        return fileId, nil, nil
+    }
+    if throw != nil {
+        manager.forget(fileId)
+        return
+    }
+    manager.track(fileId, transfer)
+    return
+}
+
+// Accept an incoming file transfer as a resumable transfer. If the connection
+// to the friend is lost, the transfer continues from the last confirmed offset
+// once the friend offers the file again.
+func (manager *ResumableTransfers) Accept(incoming *IncomingFileTransfer, writer io.WriterAt) (throw error) {
+    var tox = manager.tox
+    fileId, throw := tox.FileGetFileId(incoming.friendNumber, incoming.fileNumber)
+    if throw != nil {
+        return
+    }
+    publicKey, throw := tox.FriendGetPublicKey(incoming.friendNumber)
+    if throw != nil {
+        return
+    }
+    var state = &TransferState {
+        FileId: fileId,
+        PublicKey: publicKey,
+        Filename: incoming.Filename,
+        Size: incoming.size,
+    }
+    throw = manager.store.SaveTransfer(state)
+    if throw != nil {
+        return
+    }
+    manager.lock.Lock()
+    manager.states[fileId] = state
+    manager.saved[fileId] = 0
+    manager.writers[fileId] = writer
+    manager.lock.Unlock()
+    throw = incoming.Accept(&resumeWriter{manager, fileId, writer})
+    if throw != nil {
+        manager.forget(fileId)
+        return
+    }
+    manager.track(fileId, incoming.FileTransfer)
+    return
+}
+
+// Cancel a resumable transfer and discard its state.
+func (manager *ResumableTransfers) Cancel(fileId ToxFileId) (throw error) {
+    manager.lock.Lock()
+    var transfer = manager.active[fileId]
+    manager.lock.Unlock()
+    if (transfer != nil) {
+        throw = transfer.Cancel()
+    }
+    manager.forget(fileId)
+    return
+}
+
+// Get the current attempt of a resumable transfer. This returns nil if the
+// transfer is waiting for the friend to come online.
+func (manager *ResumableTransfers) Transfer(fileId ToxFileId) *FileTransfer {
+    manager.lock.Lock()
+    defer manager.lock.Unlock()
+    if (!manager.attempting(fileId)) {
+        return nil
+    }
+    return manager.active[fileId]
+}
+
+// Get the states of all unfinished resumable transfers.
+func (manager *ResumableTransfers) States() (states []TransferState) {
+    manager.lock.Lock()
+    defer manager.lock.Unlock()
+    for _, state := range manager.states {
+        states = append(states, *state)
+    }
+    return
+}
+
+////////////////////////////////////////////////////////////////////////////////
+////////////////////////////////// UTILITIES ///////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Write data to the destination of an incoming transfer and record the offset
+// reached.
+func (writer *resumeWriter) WriteAt(data []byte, offset int64) (n int, throw error) {
+    n, throw = writer.writer.WriteAt(data, offset)
+    if throw == nil {
+        writer.manager.advance(writer.fileId, uint64(offset) + uint64(n))
+    }
+    return
+}
+
+// Record the offset reached by a transfer, persisting it periodically.
+func (manager *ResumableTransfers) advance(fileId ToxFileId, offset uint64) {
+    manager.lock.Lock()
+    var state = manager.states[fileId]
+    if (state == nil) {
+        manager.lock.Unlock()
+        return
+    }
+    state.Offset = offset
+    if (offset < manager.saved[fileId] + resumeSaveInterval) {
+        manager.lock.Unlock()
+        return
+    }
+    manager.saved[fileId] = offset
+    var snapshot = *state
+    manager.lock.Unlock()
+    manager.store.SaveTransfer(&snapshot)
+}
+
+// Persist the current offset of a transfer.
+func (manager *ResumableTransfers) persist(fileId ToxFileId) {
+    manager.lock.Lock()
+    var state = manager.states[fileId]
+    if (state == nil) {
+        manager.lock.Unlock()
+        return
+    }
+    manager.saved[fileId] = state.Offset
+    var snapshot = *state
+    manager.lock.Unlock()
+    manager.store.SaveTransfer(&snapshot)
+}
+
+// Discard all state associated with a transfer.
+func (manager *ResumableTransfers) forget(fileId ToxFileId) {
+    manager.lock.Lock()
+    delete(manager.states, fileId)
+    delete(manager.saved, fileId)
+    delete(manager.active, fileId)
+    delete(manager.readers, fileId)
+    delete(manager.writers, fileId)
+    manager.lock.Unlock()
+    manager.store.DeleteTransfer(fileId)
+}
+
+// Track an attempt of a resumable transfer until it completes. A transfer that
+// broke because the friend went offline keeps its state, while a transfer that
+// completed, failed, or was cancelled is forgotten.
+func (manager *ResumableTransfers) track(fileId ToxFileId, transfer *FileTransfer) {
+    manager.lock.Lock()
+    manager.active[fileId] = transfer
+    manager.lock.Unlock()
+    go func() {
+        <-transfer.done
+        transfer.lock.Lock()
+        var throw = transfer.throw
+        var transferred = transfer.transferred
+        transfer.lock.Unlock()
+        if (throw != ToxErrFileTransferBroken) {
+            manager.forget(fileId)
+            return
+        }
+        manager.lock.Lock()
+        if (manager.active[fileId] == transfer) {
+            delete(manager.active, fileId)
+        }
+        var state = manager.states[fileId]
+        if (state != nil && state.Outgoing) {
+            state.Offset = transferred
+        }
+        manager.lock.Unlock()
+        manager.persist(fileId)
+    }()
+}
+
+// Check whether an attempt of a transfer is in progress. An attempt that has
+// completed does not count, even before track stops tracking it, so that a
+// transfer that broke can be resumed right away. The caller must hold the
+// manager lock.
+func (manager *ResumableTransfers) attempting(fileId ToxFileId) bool {
+    var transfer = manager.active[fileId]
+    if (transfer == nil) {
+        return false
+    }
+    transfer.lock.Lock()
+    defer transfer.lock.Unlock()
+    return !transfer.finished()
+}
+
+// Offer all pending outgoing transfers to a friend that came online.
+func (manager *ResumableTransfers) handleFriendOnline(friendNumber uint32) {
+    var tox = manager.tox
+    publicKey, err := tox.FriendGetPublicKey(friendNumber)
+    if err != nil {
+        return
+    }
+    var pending []*TransferState
+    manager.lock.Lock()
+    for fileId, state := range manager.states {
+        if (state.Outgoing && state.PublicKey == publicKey && !manager.attempting(fileId)) {
+            pending = append(pending, state)
+        }
+    }
+    manager.lock.Unlock()
+    for _, state := range pending {
+        manager.lock.Lock()
+        var reader = manager.readers[state.FileId]
+        manager.lock.Unlock()
+        if (reader == nil) {
+            if (manager.source == nil) {
+                continue
+            }
+            reader, err = manager.source(state)
+            if err != nil {
+                continue
+            }
+            manager.lock.Lock()
+            manager.readers[state.FileId] = reader
+            manager.lock.Unlock()
+        }
+        var fileId = state.FileId
+        transfer, err := tox.sendFile(friendNumber, &fileId, state.Filename, reader, state.Size)
+        if err != nil {
+            continue
+        }
+        manager.track(fileId, transfer)
+    }
+}
+
+// Resume an incoming transfer offered again by a friend. This returns false if
+// the offer does not match a pending incoming transfer.
+func (manager *ResumableTransfers) handleFileRecv(incoming *IncomingFileTransfer) bool {
+    var tox = manager.tox
+    fileId, err := tox.FileGetFileId(incoming.friendNumber, incoming.fileNumber)
+    if err != nil {
+        return false
+    }
+    publicKey, err := tox.FriendGetPublicKey(incoming.friendNumber)
+    if err != nil {
+        return false
+    }
+    manager.lock.Lock()
+    var state = manager.states[fileId]
+    if (state == nil || state.Outgoing || state.PublicKey != publicKey || manager.attempting(fileId)) {
+        manager.lock.Unlock()
+        return false
+    }
+    var offset = manager.saved[fileId]
+    var writer = manager.writers[fileId]
+    manager.lock.Unlock()
+    if (writer == nil) {
+        if (manager.sink == nil) {
+            return false
+        }
+        writer, err = manager.sink(state)
+        if err != nil {
+            return false
+        }
+        manager.lock.Lock()
+        manager.writers[fileId] = writer
+        manager.lock.Unlock()
+    }
+    if (offset > 0) {
+        if (tox.FileSeek(incoming.friendNumber, incoming.fileNumber, offset) != nil) {
+            offset = 0
+        }
+    }
+    manager.lock.Lock()
+    state.Offset = offset
+    manager.saved[fileId] = offset
+    manager.lock.Unlock()
+    incoming.lock.Lock()
+    incoming.transferred = offset
+    incoming.lock.Unlock()
+    if (incoming.Accept(&resumeWriter{manager, fileId, writer}) != nil) {
+        return true
+    }
+    manager.track(fileId, incoming.FileTransfer)
+    return true
+}
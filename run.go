@@ -0,0 +1,146 @@
+/**
+ * File        : run.go
+ * Copyright   : Copyright (c) 2015-2017 Mirror Labs, Inc. All rights reserved.
+ * License     : GPLv3
+ * Maintainer  : Enzo Haussecker <enzo@mirror.co>, Dominic Williams <dominic@string.technology>
+ * Stability   : Experimental
+ * Portability : Non-portable (requires Tox core at commit dcf2aaa)
+ *
+ * This module provides an event loop that calls Process at the interval
+ * requested by the core. API calls that queue outgoing data wake the loop so
+ * that the data is sent without waiting for the next scheduled iteration.
+ */
+
+package tox
+
+import "context"
+import "sync"
+import "time"
+
+////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////// STRUCT TYPES /////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// This type holds statistics about the iterations of the event loop. An
+// iteration overruns when it takes longer than the interval requested by the
+// core, which usually means the callbacks are too slow.
+type RunStats struct {
+
+    // The number of iterations performed.
+    Iterations uint64
+
+    // The number of iterations that overran the requested interval.
+    Overruns uint64
+
+    // The total time by which iterations overran the requested interval.
+    TotalOverrun time.Duration
+
+    // The largest time by which an iteration overran the requested interval.
+    MaxOverrun time.Duration
+
+    // The duration of the most recent iteration.
+    LastDuration time.Duration
+
+}
+
+// This type holds the state of the event loop of a Tox instance.
+type runState struct {
+    wake  chan struct{}
+    done  chan struct{}
+    lock  sync.Mutex
+    stats RunStats
+}
+
+////////////////////////////////////////////////////////////////////////////////
+/////////////////////////////////// EVENT LOOP /////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Run the event loop until the context is cancelled or the instance is
+// destroyed. This calls Process at the interval requested by the core, and
+// earlier when an API call needs the loop. This returns the error of the
+// context if it is cancelled, and nil if the instance is destroyed. If the
+// instance has a dedicated thread, then this only waits, since the thread runs
+// the loop itself.
+func (tox *Tox) Run(ctx context.Context) error {
+    if (tox.owner != nil) {
+        select {
+            case <-ctx.Done():
+                return ctx.Err()
+            case <-tox.run.done:
+                return nil
+        }
+    }
+    var timer = time.NewTimer(0)
+    defer timer.Stop()
+    for {
+        select {
+            case <-ctx.Done():
+                return ctx.Err()
+            case <-tox.run.done:
+                return nil
+            case <-timer.C:
+            case <-tox.run.wake:
+                if (!timer.Stop()) {
+                    select {
+                        case <-timer.C:
+                        default:
+                    }
+                }
+        }
+        var interval = tox.ProcessDelay()
+        var start = time.Now()
+        tox.Process()
+        var elapsed = time.Since(start)
+        tox.run.record(interval, elapsed)
+        if (elapsed < interval) {
+            timer.Reset(interval - elapsed)
+        } else {
+            timer.Reset(0)
+        }
+    }
+}
+
+// Wake the event loop so that it iterates immediately. API calls that queue
+// outgoing data do this automatically.
+func (tox *Tox) Wake() {
+    tox.wakeup()
+}
+
+// Get the statistics of the event loop.
+func (tox *Tox) RunStats() RunStats {
+    tox.run.lock.Lock()
+    defer tox.run.lock.Unlock()
+    return tox.run.stats
+}
+
+// Create the state of the event loop.
+func newRunState() runState {
+    return runState {
+        wake: make(chan struct{}, 1),
+        done: make(chan struct{}),
+    }
+}
+
+// Wake the event loop without blocking. Pending wakeups are coalesced.
+func (tox *Tox) wakeup() {
+    select {
+        case tox.run.wake <- struct{}{}:
+        default:
+    }
+}
+
+// Record the duration of an iteration.
+func (run *runState) record(interval time.Duration, elapsed time.Duration) {
+    run.lock.Lock()
+    defer run.lock.Unlock()
+    run.stats.Iterations++
+    run.stats.LastDuration = elapsed
+    if (elapsed > interval) {
+        var overrun = elapsed - interval
+        run.stats.Overruns++
+        run.stats.TotalOverrun += overrun
+        if (overrun > run.stats.MaxOverrun) {
+            run.stats.MaxOverrun = overrun
+        }
+    }
+}
@@ -0,0 +1,101 @@
+/**
+ * File        : split.go
+ * Copyright   : Copyright (c) 2015-2017 Mirror Labs, Inc. All rights reserved.
+ * License     : GPLv3
+ * Maintainer  : Enzo Haussecker <enzo@mirror.co>, Dominic Williams <dominic@string.technology>
+ * Stability   : Experimental
+ * Portability : Non-portable (requires Tox core at commit dcf2aaa)
+ *
+ * This module splits chat messages that exceed the maximum message length into
+ * several messages. Messages are split at word boundaries where possible, and
+ * never inside a multi-byte UTF-8 character.
+ */
+
+package tox
+
+import "unicode"
+import "unicode/utf8"
+
+////////////////////////////////////////////////////////////////////////////////
+/////////////////////////////// MESSAGE SPLITTING //////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Split a message into parts of at most limit bytes. Each part after the first
+// is preceded by the given continuation marker, which may be empty. Parts end
+// after a whitespace character where possible, otherwise at the last complete
+// UTF-8 character that fits. Concatenating the parts without their markers
+// yields the original message. If the marker leaves no room for a complete
+// character, then it is omitted.
+func SplitMessage(message []byte, limit int, marker []byte) (parts [][]byte) {
+    if (limit < utf8.UTFMax) {
+        limit = utf8.UTFMax
+    }
+    if (limit - len(marker) < utf8.UTFMax) {
+        marker = nil
+    }
+    var rest = message
+    for (len(rest) > 0) {
+        var room = limit
+        if (len(parts) > 0) {
+            room -= len(marker)
+        }
+        var cut = splitPoint(rest, room)
+        var part = make([]byte, 0, len(marker) + cut)
+        if (len(parts) > 0) {
+            part = append(part, marker...)
+        }
+        part = append(part, rest[:cut]...)
+        parts = append(parts, part)
+        rest = rest[cut:]
+    }
+    return
+}
+
+// Send a chat message of any length to an online friend. Messages longer than
+// ToxMaxMessageLength are split with SplitMessage and sent in order. This
+// returns the identifiers of the messages sent. If sending a part fails, then
+// the identifiers of the parts already sent are returned along with the error.
+func (tox *Tox) FriendSendSplitMessage(friendNumber uint32, messageType ToxMessageType, message []byte, marker []byte) (messageIds []uint32, throw error) {
+    if (len(message) == 0) {
+        return nil, ToxErrFriendSendMessageEmpty
+    }
+    for _, part := range SplitMessage(message, ToxMaxMessageLength, marker) {
+        var messageId uint32
+        messageId, throw = tox.FriendSendMessage(friendNumber, messageType, part)
+        if throw != nil {
+            return
+        }
+        messageIds = append(messageIds, messageId)
+    }
+    return
+}
+
+// Find the length of the next part of a message that must fit in the given
+// number of bytes.
+func splitPoint(message []byte, room int) int {
+    if (len(message) <= room) {
+        return len(message)
+    }
+    var space = 0
+    for i := 0; i < room; {
+        r, size := utf8.DecodeRune(message[i:])
+        if (i + size > room) {
+            break
+        }
+        i += size
+        if (unicode.IsSpace(r)) {
+            space = i
+        }
+    }
+    if (space > 0) {
+        return space
+    }
+    var cut = room
+    for back := 0; back < utf8.UTFMax && cut > 0; back++ {
+        if (utf8.RuneStart(message[cut])) {
+            return cut
+        }
+        cut--
+    }
+    return room
+}
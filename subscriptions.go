@@ -0,0 +1,308 @@
+/**
+ * File        : subscriptions.go
+ * Copyright   : Copyright (c) 2015-2017 Mirror Labs, Inc. All rights reserved.
+ * License     : GPLv3
+ * Maintainer  : Enzo Haussecker <enzo@mirror.co>, Dominic Williams <dominic@string.technology>
+ * Stability   : Experimental
+ * Portability : Non-portable (requires Tox core at commit dcf2aaa)
+ *
+ * The setters in tox.go allow one callback per event. This module allows any
+ * number of callbacks to subscribe to an event. Subscribers run in the order
+ * in which they subscribed, after the callback registered through the setter.
+ * Subscribing and unsubscribing are safe at any time, including from within a
+ * callback, in which case the change takes effect from the next event.
+ */
+
+package tox
+
+//#include "callbacks.h"
+import "C"
+import "sync"
+
+////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////// STRUCT TYPES /////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// This type holds the subscribers to an event. The slice of subscribers is
+// replaced rather than modified, so a snapshot can be iterated without holding
+// the lock.
+type subscribers struct {
+    lock    sync.Mutex
+    next    uint64
+    entries []subscriber
+}
+
+// This type represents a subscriber to an event.
+type subscriber struct {
+    id       uint64
+    callback interface{}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+////////////////////////////////// ENUM TYPES //////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// This type identifies an event that can be subscribed to.
+type subscription int
+
+const (
+    subscribeSelfConnectionStatus subscription = iota
+    subscribeFriendRequest
+    subscribeFriendName
+    subscribeFriendStatus
+    subscribeFriendStatusMessage
+    subscribeFriendConnectionStatus
+    subscribeFriendTyping
+    subscribeFriendMessage
+    subscribeFriendReadReceipt
+    subscribeFriendLossyPacket
+    subscribeFriendLosslessPacket
+    subscribeFileRecvControl
+    subscribeFileChunkRequest
+    subscribeFileRecv
+    subscribeFileRecvChunk
+    subscribeConferenceInvite
+    subscribeConferenceMessage
+    subscribeConferenceTitle
+    subscribeConferencePeerListChanged
+    subscriptionCount
+)
+
+////////////////////////////////////////////////////////////////////////////////
+////////////////////////////////// SUBSCRIBERS /////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Add a subscriber and return a function that removes it. The function can be
+// called more than once.
+func (subscribers *subscribers) add(callback interface{}) (unsubscribe func()) {
+    subscribers.lock.Lock()
+    defer subscribers.lock.Unlock()
+    subscribers.next++
+    var id = subscribers.next
+    var entries = make([]subscriber, len(subscribers.entries), len(subscribers.entries) + 1)
+    copy(entries, subscribers.entries)
+    subscribers.entries = append(entries, subscriber{id, callback})
+    return func() {
+        subscribers.remove(id)
+    }
+}
+
+// Remove a subscriber.
+func (subscribers *subscribers) remove(id uint64) {
+    subscribers.lock.Lock()
+    defer subscribers.lock.Unlock()
+    for i, entry := range subscribers.entries {
+        if (entry.id == id) {
+            var entries = make([]subscriber, 0, len(subscribers.entries) - 1)
+            entries = append(entries, subscribers.entries[:i]...)
+            subscribers.entries = append(entries, subscribers.entries[i + 1:]...)
+            return
+        }
+    }
+}
+
+// Get the current subscribers in the order in which they subscribed.
+func (subscribers *subscribers) snapshot() []subscriber {
+    subscribers.lock.Lock()
+    defer subscribers.lock.Unlock()
+    return subscribers.entries
+}
+
+////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////// SUBSCRIPTIONS ////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Subscribe a function that executes when the connection status of the client
+// changes. This returns a function that cancels the subscription.
+func (tox *Tox) OnSelfConnectionStatus(callback OnSelfConnectionStatus) (unsubscribe func()) {
+    if (callback == nil) {
+        return func() {}
+    }
+    unsubscribe = tox.subscriptions[subscribeSelfConnectionStatus].add(callback)
+    tox.exec(nil, func() {
+        C.register_self_connection_status(tox.handle, C.uintptr_t(tox.id))
+    })
+    return
+}
+
+// Subscribe a function that executes when receiving a friend request. This
+// returns a function that cancels the subscription.
+func (tox *Tox) OnFriendRequest(callback OnFriendRequest) (unsubscribe func()) {
+    if (callback == nil) {
+        return func() {}
+    }
+    unsubscribe = tox.subscriptions[subscribeFriendRequest].add(callback)
+    tox.exec(nil, func() {
+        C.register_friend_request(tox.handle, C.uintptr_t(tox.id))
+    })
+    return
+}
+
+// Subscribe a function that executes when a friend changes their name. This
+// returns a function that cancels the subscription.
+func (tox *Tox) OnFriendName(callback OnFriendName) (unsubscribe func()) {
+    if (callback == nil) {
+        return func() {}
+    }
+    unsubscribe = tox.subscriptions[subscribeFriendName].add(callback)
+    tox.exec(nil, func() {
+        C.register_friend_name(tox.handle, C.uintptr_t(tox.id))
+    })
+    return
+}
+
+// Subscribe a function that executes when a friend changes their user status.
+// This returns a function that cancels the subscription.
+func (tox *Tox) OnFriendStatus(callback OnFriendStatus) (unsubscribe func()) {
+    if (callback == nil) {
+        return func() {}
+    }
+    unsubscribe = tox.subscriptions[subscribeFriendStatus].add(callback)
+    tox.exec(nil, func() {
+        C.register_friend_status(tox.handle, C.uintptr_t(tox.id))
+    })
+    return
+}
+
+// Subscribe a function that executes when a friend changes their status
+// message. This returns a function that cancels the subscription.
+func (tox *Tox) OnFriendStatusMessage(callback OnFriendStatusMessage) (unsubscribe func()) {
+    if (callback == nil) {
+        return func() {}
+    }
+    unsubscribe = tox.subscriptions[subscribeFriendStatusMessage].add(callback)
+    tox.exec(nil, func() {
+        C.register_friend_status_message(tox.handle, C.uintptr_t(tox.id))
+    })
+    return
+}
+
+// Subscribe a function that executes when the connection status of a friend
+// changes. This returns a function that cancels the subscription.
+func (tox *Tox) OnFriendConnectionStatus(callback OnFriendConnectionStatus) (unsubscribe func()) {
+    if (callback == nil) {
+        return func() {}
+    }
+    unsubscribe = tox.subscriptions[subscribeFriendConnectionStatus].add(callback)
+    tox.exec(nil, func() {
+        C.register_friend_connection_status(tox.handle, C.uintptr_t(tox.id))
+    })
+    return
+}
+
+// Subscribe a function that executes when a friend starts or stops typing. This
+// returns a function that cancels the subscription.
+func (tox *Tox) OnFriendTyping(callback OnFriendTyping) (unsubscribe func()) {
+    if (callback == nil) {
+        return func() {}
+    }
+    unsubscribe = tox.subscriptions[subscribeFriendTyping].add(callback)
+    tox.exec(nil, func() {
+        C.register_friend_typing(tox.handle, C.uintptr_t(tox.id))
+    })
+    return
+}
+
+// Subscribe a function that executes when receiving a chat message from a
+// friend. This returns a function that cancels the subscription.
+func (tox *Tox) OnFriendMessage(callback OnFriendMessage) (unsubscribe func()) {
+    if (callback == nil) {
+        return func() {}
+    }
+    unsubscribe = tox.subscriptions[subscribeFriendMessage].add(callback)
+    tox.exec(nil, func() {
+        C.register_friend_message(tox.handle, C.uintptr_t(tox.id))
+    })
+    return
+}
+
+// Subscribe a function that executes when a friend receives a chat message.
+// This returns a function that cancels the subscription.
+func (tox *Tox) OnFriendReadReceipt(callback OnFriendReadReceipt) (unsubscribe func()) {
+    if (callback == nil) {
+        return func() {}
+    }
+    unsubscribe = tox.subscriptions[subscribeFriendReadReceipt].add(callback)
+    tox.exec(nil, func() {
+        C.register_friend_read_receipt(tox.handle, C.uintptr_t(tox.id))
+    })
+    return
+}
+
+// Subscribe a function that executes when receiving a lossy custom packet from
+// a friend. This returns a function that cancels the subscription.
+func (tox *Tox) OnFriendLossyPacket(callback OnFriendLossyPacket) (unsubscribe func()) {
+    if (callback == nil) {
+        return func() {}
+    }
+    unsubscribe = tox.subscriptions[subscribeFriendLossyPacket].add(callback)
+    tox.exec(nil, func() {
+        C.register_friend_lossy_packet(tox.handle, C.uintptr_t(tox.id))
+    })
+    return
+}
+
+// Subscribe a function that executes when receiving a lossless custom packet
+// from a friend. This returns a function that cancels the subscription.
+func (tox *Tox) OnFriendLosslessPacket(callback OnFriendLosslessPacket) (unsubscribe func()) {
+    if (callback == nil) {
+        return func() {}
+    }
+    unsubscribe = tox.subscriptions[subscribeFriendLosslessPacket].add(callback)
+    tox.exec(nil, func() {
+        C.register_friend_lossless_packet(tox.handle, C.uintptr_t(tox.id))
+    })
+    return
+}
+
+// Subscribe a function that executes when a friend sends a file control
+// command. This returns a function that cancels the subscription.
+func (tox *Tox) OnFileRecvControl(callback OnFileRecvControl) (unsubscribe func()) {
+    if (callback == nil) {
+        return func() {}
+    }
+    unsubscribe = tox.subscriptions[subscribeFileRecvControl].add(callback)
+    tox.exec(nil, func() {
+        C.register_file_recv_control(tox.handle, C.uintptr_t(tox.id))
+    })
+    return
+}
+
+// Subscribe a function that executes when a friend requests a chunk of an
+// outgoing file. This returns a function that cancels the subscription.
+func (tox *Tox) OnFileChunkRequest(callback OnFileChunkRequest) (unsubscribe func()) {
+    if (callback == nil) {
+        return func() {}
+    }
+    unsubscribe = tox.subscriptions[subscribeFileChunkRequest].add(callback)
+    tox.exec(nil, func() {
+        C.register_file_chunk_request(tox.handle, C.uintptr_t(tox.id))
+    })
+    return
+}
+
+// Subscribe a function that executes when a friend offers to send a file. This
+// returns a function that cancels the subscription.
+func (tox *Tox) OnFileRecv(callback OnFileRecv) (unsubscribe func()) {
+    if (callback == nil) {
+        return func() {}
+    }
+    unsubscribe = tox.subscriptions[subscribeFileRecv].add(callback)
+    tox.exec(nil, func() {
+        C.register_file_recv(tox.handle, C.uintptr_t(tox.id))
+    })
+    return
+}
+
+// Subscribe a function that executes when receiving a chunk of an incoming
+// file. This returns a function that cancels the subscription.
+func (tox *Tox) OnFileRecvChunk(callback OnFileRecvChunk) (unsubscribe func()) {
+    if (callback == nil) {
+        return func() {}
+    }
+    unsubscribe = tox.subscriptions[subscribeFileRecvChunk].add(callback)
+    tox.exec(nil, func() {
+        C.register_file_recv_chunk(tox.handle, C.uintptr_t(tox.id))
+    })
+    return
+}
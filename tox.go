@@ -10,15 +10,19 @@
  * using the Tox protocol.
  */
 
+//go:generate go run ./toxgen -header=$TOX_HEADER
+
 package tox
 
 //#cgo LDFLAGS: -l toxcore
+//#cgo toxcore02 CFLAGS: -DTOXCORE_02
 //#include "callbacks.h"
 //#include <memory.h>
 import "C"
 import "encoding/hex"
 import "errors"
-import "sync"
+import "runtime"
+import "strings"
 import "time"
 import "unsafe"
 
@@ -56,9 +60,9 @@ func array2slice(array unsafe.Pointer, size int) []byte {
 // Convert startup options from C to Go.
 func GoOptions(c_options *C.struct_Tox_Options) (options *ToxOptions, throw error) {
     options = &ToxOptions{}
-    options.IPv6Enabled = bool(c_options.ipv6_enabled)
-    options.UDPEnabled  = bool(c_options.udp_enabled)
-    switch c_options.proxy_type {
+    options.IPv6Enabled = bool(C.tox_options_get_ipv6_enabled(c_options))
+    options.UDPEnabled  = bool(C.tox_options_get_udp_enabled(c_options))
+    switch C.tox_options_get_proxy_type(c_options) {
         case C.TOX_PROXY_TYPE_NONE:
             options.ProxyType = ToxProxyTypeNone
         case C.TOX_PROXY_TYPE_HTTP:
@@ -68,14 +72,24 @@ func GoOptions(c_options *C.struct_Tox_Options) (options *ToxOptions, throw erro
         default:
             return nil, errors.New("unknown proxy type")
     }
-    options.ProxyHost = C.GoString(c_options.proxy_host)
-    options.ProxyPort = uint16(c_options.proxy_port)
-    options.StartPort = uint16(c_options.start_port)
-    options.EndPort   = uint16(c_options.end_port)
-    options.TCPPort   = uint16(c_options.tcp_port)
+    options.ProxyHost = C.GoString(C.tox_options_get_proxy_host(c_options))
+    options.ProxyPort = uint16(C.tox_options_get_proxy_port(c_options))
+    options.StartPort = uint16(C.tox_options_get_start_port(c_options))
+    options.EndPort   = uint16(C.tox_options_get_end_port(c_options))
+    options.TCPPort   = uint16(C.tox_options_get_tcp_port(c_options))
+    switch C.tox_options_get_savedata_type(c_options) {
+        case C.TOX_SAVEDATA_TYPE_NONE:
+            options.SaveDataType = ToxSaveDataTypeNone
+        case C.TOX_SAVEDATA_TYPE_TOX_SAVE:
+            options.SaveDataType = ToxSaveDataTypeToxSave
+        case C.TOX_SAVEDATA_TYPE_SECRET_KEY:
+            options.SaveDataType = ToxSaveDataTypeSecretKey
+        default:
+            return nil, errors.New("unknown save data type")
+    }
     options.SaveData  = array2slice(
-        unsafe.Pointer(c_options.savedata_data),
-        int(c_options.savedata_length),
+        unsafe.Pointer(C.tox_options_get_savedata_data(c_options)),
+        int(C.tox_options_get_savedata_length(c_options)),
     )
     return options, nil
 }
@@ -84,46 +98,75 @@ func GoOptions(c_options *C.struct_Tox_Options) (options *ToxOptions, throw erro
 // that must be freed to prevent memory leaks. It is the caller's responsibility
 // to arrange for them to be freed.
 func COptions(options *ToxOptions) (c_options *C.struct_Tox_Options, throw error) {
-    c_options = &C.struct_Tox_Options{}
-    c_options.ipv6_enabled = C.bool(options.IPv6Enabled)
-    c_options.udp_enabled  = C.bool(options.UDPEnabled)
+    var c_proxy_type C.TOX_PROXY_TYPE
     switch options.ProxyType {
         case ToxProxyTypeNone:
-            c_options.proxy_type = C.TOX_PROXY_TYPE_NONE
+            c_proxy_type = C.TOX_PROXY_TYPE_NONE
         case ToxProxyTypeHttp:
-            c_options.proxy_type = C.TOX_PROXY_TYPE_HTTP
+            c_proxy_type = C.TOX_PROXY_TYPE_HTTP
         case ToxProxyTypeSocks5:
-            c_options.proxy_type = C.TOX_PROXY_TYPE_SOCKS5
+            c_proxy_type = C.TOX_PROXY_TYPE_SOCKS5
         default:
             return nil, errors.New("unknown proxy type")
     }
-    c_options.proxy_host = C.CString(options.ProxyHost)
-    c_options.proxy_port = C.uint16_t(options.ProxyPort)
-    c_options.start_port = C.uint16_t(options.StartPort)
-    c_options.end_port   = C.uint16_t(options.EndPort)
-    c_options.tcp_port   = C.uint16_t(options.TCPPort)
-    var length = len(options.SaveData)
-    if (length == 0) {
-        c_options.savedata_type = C.TOX_SAVEDATA_TYPE_NONE
-    } else {
-        c_options.savedata_type = C.TOX_SAVEDATA_TYPE_TOX_SAVE
+    var c_savedata_type C.TOX_SAVEDATA_TYPE
+    switch options.SaveDataType {
+        case ToxSaveDataTypeNone:
+            if (len(options.SaveData) == 0) {
+                c_savedata_type = C.TOX_SAVEDATA_TYPE_NONE
+            } else {
+                c_savedata_type = C.TOX_SAVEDATA_TYPE_TOX_SAVE
+            }
+        case ToxSaveDataTypeToxSave:
+            c_savedata_type = C.TOX_SAVEDATA_TYPE_TOX_SAVE
+        case ToxSaveDataTypeSecretKey:
+            c_savedata_type = C.TOX_SAVEDATA_TYPE_SECRET_KEY
+        default:
+            return nil, errors.New("unknown save data type")
     }
-    c_options.savedata_data = (*C.uint8_t)(slice2array(options.SaveData))
-    c_options.savedata_length = C.size_t(length)
+    var c_error C.TOX_ERR_OPTIONS_NEW
+    c_options = C.tox_options_new(&c_error)
+    if (c_error != C.TOX_ERR_OPTIONS_NEW_OK) {
+        return nil, errorOptionsNew("tox_options_new", c_error)
+    }
+    C.tox_options_set_ipv6_enabled(c_options, C.bool(options.IPv6Enabled))
+    C.tox_options_set_udp_enabled(c_options, C.bool(options.UDPEnabled))
+    C.tox_options_set_proxy_type(c_options, c_proxy_type)
+    C.tox_options_set_proxy_host(c_options, C.CString(options.ProxyHost))
+    C.tox_options_set_proxy_port(c_options, C.uint16_t(options.ProxyPort))
+    C.tox_options_set_start_port(c_options, C.uint16_t(options.StartPort))
+    C.tox_options_set_end_port(c_options, C.uint16_t(options.EndPort))
+    C.tox_options_set_tcp_port(c_options, C.uint16_t(options.TCPPort))
+    var length = len(options.SaveData)
+    C.tox_options_set_savedata_type(c_options, c_savedata_type)
+    C.tox_options_set_savedata_data(
+        c_options,
+        (*C.uint8_t)(slice2array(options.SaveData)),
+        C.size_t(length),
+    )
     return c_options, nil
 }
 
 // Free all resources associated with a startup options object.
-func (c_options *C.struct_Tox_Options) FreeOptions() {
-    C.free(unsafe.Pointer(c_options.proxy_host))
-    C.free(unsafe.Pointer(c_options.savedata_data))
+func freeOptions(c_options *C.struct_Tox_Options) {
+    var c_savedata = unsafe.Pointer(C.tox_options_get_savedata_data(c_options))
+    if (c_savedata != nil) {
+        C.memset(c_savedata, 0, C.tox_options_get_savedata_length(c_options))
+    }
+    C.free(unsafe.Pointer(C.tox_options_get_proxy_host(c_options)))
+    C.free(c_savedata)
+    C.tox_options_free(c_options)
 }
 
 // The default startup options for Tox.
 func DefaultOptions() (options *ToxOptions, throw error) {
-    var c_options C.struct_Tox_Options
-    C.tox_options_default(unsafe.Pointer(&c_options))
-    return GoOptions(&c_options)
+    var c_error C.TOX_ERR_OPTIONS_NEW
+    var c_options = C.tox_options_new(&c_error)
+    if (c_error != C.TOX_ERR_OPTIONS_NEW_OK) {
+        return nil, errorOptionsNew("tox_options_new", c_error)
+    }
+    defer C.tox_options_free(c_options)
+    return GoOptions(c_options)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -132,81 +175,163 @@ func DefaultOptions() (options *ToxOptions, throw error) {
 
 // Create or restore a Tox instance. This will bring the instance into a valid
 // state. If the startup options are nil, then the default options are used.
+// This fails with ToxErrVersionMismatch if the library does not implement the
+// API that the bindings were built for. Encrypted save data is decrypted with
+// the passphrase given in the startup options. The log records of the core
+// are delivered to the logger given in the startup options.
 func New(options *ToxOptions) (tox *Tox, throw error) {
+    if (!VersionIsCompatible()) {
+        return nil, ToxErrVersionMismatch
+    }
     var c_options *C.struct_Tox_Options
     var c_error C.TOX_ERR_NEW
-    if (options != nil) {
-        c_options, throw = COptions(options)
+    if (options != nil && len(options.Passphrase) > 0 && IsDataEncrypted(options.SaveData)) {
+        var decrypted = *options
+        decrypted.SaveData, throw = DecryptSave(options.SaveData, options.Passphrase)
         if throw != nil {
             return
         }
-        defer c_options.FreeOptions()
+        defer wipe(decrypted.SaveData)
+        options = &decrypted
+    }
+    if (options == nil) {
+        options, throw = DefaultOptions()
+        if throw != nil {
+            return
+        }
+    }
+    c_options, throw = COptions(options)
+    if throw != nil {
+        return
     }
+    defer freeOptions(c_options)
+    var logId = loggers.register(newLogSink(options))
+    C.set_log_callback(c_options, C.uintptr_t(logId))
     var c_tox = C.tox_new(c_options, &c_error)
     if (c_error != C.TOX_ERR_NEW_OK) {
-        switch c_error {
-            case C.TOX_ERR_NEW_NULL:
-                throw = ToxErrNewNull
-            case C.TOX_ERR_NEW_MALLOC:
-                throw = ToxErrNewMalloc
-            case C.TOX_ERR_NEW_PORT_ALLOC:
-                throw = ToxErrNewPortAlloc
-            case C.TOX_ERR_NEW_PROXY_BAD_TYPE:
-                throw = ToxErrNewProxyBadType
-            case C.TOX_ERR_NEW_PROXY_BAD_HOST:
-                throw = ToxErrNewProxyBadHost
-            case C.TOX_ERR_NEW_PROXY_BAD_PORT:
-                throw = ToxErrNewProxyBadPort
-            case C.TOX_ERR_NEW_PROXY_NOT_FOUND:
-                throw = ToxErrNewProxyNotFound
-            case C.TOX_ERR_NEW_LOAD_ENCRYPTED:
-                throw = ToxErrNewLoadEncrypted
-            case C.TOX_ERR_NEW_LOAD_BAD_FORMAT:
-                throw = ToxErrNewLoadBadFormat
-            default:
-                throw = ToxErrUnknown
-        }
+        loggers.unregister(logId)
+        throw = errorNew("tox_new", c_error)
     } else {
-        tox = &Tox {
-            handle: c_tox,
-            lock: sync.Mutex{},
+        tox = &Tox{newToxState()}
+        tox.handle = c_tox
+        tox.logId = logId
+        tox.id = instances.register(tox)
+        runtime.SetFinalizer(tox, finalize)
+        if (options.DedicatedThread) {
+            tox.startOwner()
         }
     }
     return
 }
 
+// Create a Tox instance from a secret key. The instance has the identity that
+// belongs to the key, but no friends, name or status. A random no-spam value
+// is chosen by the core. If the startup options are nil, then the default
+// options are used. Any save data in the startup options is ignored.
+func NewFromSecretKey(secretKey ToxSecretKey, options *ToxOptions) (tox *Tox, throw error) {
+    if (options == nil) {
+        options, throw = DefaultOptions()
+        if throw != nil {
+            return
+        }
+    }
+    var copied = *options
+    copied.SaveDataType = ToxSaveDataTypeSecretKey
+    copied.SaveData = secretKey[:]
+    copied.Passphrase = nil
+    defer wipe(copied.SaveData)
+    return New(&copied)
+}
+
 // Serialize a Tox instance.
 func (tox *Tox) Serialize() (data []byte) {
-    var c_length = C.tox_get_savedata_size(tox.handle)
-    data = make([]byte, c_length)
-    if (c_length > 0) {
-        C.tox_get_savedata(tox.handle, (*C.uint8_t)(&data[0]))
-    }
+    tox.exec(nil, func() {
+        var c_length = C.tox_get_savedata_size(tox.handle)
+        data = make([]byte, c_length)
+        if (c_length > 0) {
+            C.tox_get_savedata(tox.handle, (*C.uint8_t)(&data[0]))
+        }
+    })
     return
 }
 
 // Destroy a Tox instance. This will disconnect the instance from the Tox
 // network and release all other resources associated with it. The Tox pointer
-// becomes invalid and can no longer be used.
+// becomes invalid and can no longer be used. If the event loop is running, then
+// this waits for the current iteration to finish and stops the loop. If this is
+// called from a callback, then the remaining events of the iteration are
+// discarded. Any open event streams are closed. Destroying an instance more
+// than once has no effect. Afterwards, methods that return an error return
+// ToxErrClosed, and other methods do nothing and return zero values.
 func (tox *Tox) Destroy() {
-    C.tox_kill(tox.handle)
+    tox.exec(nil, func() {
+        close(tox.run.done)
+        instances.unregister(tox.id)
+        C.tox_kill(tox.handle)
+        loggers.unregister(tox.logId)
+        tox.handle = nil
+    })
+    tox.closeStreams()
+}
+
+// Create the state of a Tox instance, along with the instance that the package
+// uses internally.
+func newToxState() *toxState {
+    var state = &toxState { run: newRunState() }
+    state.view = &Tox{state}
+    return state
+}
+
+// Destroy a Tox instance that is garbage collected without being destroyed.
+func finalize(tox *Tox) {
+    if (!tox.closed()) {
+        if sink := loggers.get(tox.logId); (sink != nil) {
+            sink.log(ToxLogLevelWarning, "finalize", "destroying a Tox instance that was leaked without calling Destroy")
+        }
+        tox.Destroy()
+    }
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 /////////////////////////////// EVENT PROCESSING ///////////////////////////////
 ////////////////////////////////////////////////////////////////////////////////
 
-// Run the main event processing loop.
+// Run the main event processing loop. The core queues events while it is
+// processing, and the callbacks run afterwards without holding the lock of the
+// instance, so they may call any method other than Process. Concurrent calls to
+// Process are serialized. If the instance has a dedicated thread, then this
+// only requests an immediate iteration, since the thread runs the loop itself.
 func (tox *Tox) Process() {
+    if (tox.owner != nil) {
+        tox.wakeup()
+        return
+    }
+    tox.processLock.Lock()
+    defer tox.processLock.Unlock()
     tox.lock.Lock()
-    C.tox_iterate(tox.handle)
+    if (tox.closed()) {
+        tox.lock.Unlock()
+        return
+    }
+    C.iterate(tox.handle, C.uintptr_t(tox.id))
+    var pending = tox.pending
+    tox.pending = nil
     tox.lock.Unlock()
+    for _, handler := range pending {
+        if (tox.closed()) {
+            return
+        }
+        handler()
+    }
 }
 
 // Get the iteration interval in milliseconds.
-func (tox *Tox) ProcessDelay() time.Duration {
-    var c_millis = C.tox_iteration_interval(tox.handle)
-    return time.Duration(uint32(c_millis)) * time.Millisecond
+func (tox *Tox) ProcessDelay() (delay time.Duration) {
+    tox.exec(nil, func() {
+        var c_millis = C.tox_iteration_interval(tox.handle)
+        delay = time.Duration(uint32(c_millis)) * time.Millisecond
+    })
+    return
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -216,57 +341,136 @@ func (tox *Tox) ProcessDelay() time.Duration {
 // This function registers a function that executes when the connection status
 // of the client changes.
 func (tox *Tox) SetOnSelfConnectionStatus(callback OnSelfConnectionStatus) {
-    tox.onSelfConnectionStatus = callback
-    C.register_self_connection_status(tox.handle, unsafe.Pointer(tox))
+    tox.exec(nil, func() {
+        tox.onSelfConnectionStatus = callback
+        C.register_self_connection_status(tox.handle, C.uintptr_t(tox.id))
+    })
 }
 
 // This function registers a function that executes when receiving a friend
 // request.
 func (tox *Tox) SetOnFriendRequest(callback OnFriendRequest) {
-    tox.onFriendRequest = callback
-    C.register_friend_request(tox.handle, unsafe.Pointer(tox))
+    tox.exec(nil, func() {
+        tox.onFriendRequest = callback
+        C.register_friend_request(tox.handle, C.uintptr_t(tox.id))
+    })
 }
 
 // This function registers a function that executes when a friend changes their
 // name.
 func (tox *Tox) SetOnFriendName(callback OnFriendName) {
-    tox.onFriendName = callback
-    C.register_friend_name(tox.handle, unsafe.Pointer(tox))
+    tox.exec(nil, func() {
+        tox.onFriendName = callback
+        C.register_friend_name(tox.handle, C.uintptr_t(tox.id))
+    })
 }
 
 // This function registers a function that executes when a friend changes their
 // status.
 func (tox *Tox) SetOnFriendStatus(callback OnFriendStatus) {
-    tox.onFriendStatus = callback
-    C.register_friend_status(tox.handle, unsafe.Pointer(tox))
+    tox.exec(nil, func() {
+        tox.onFriendStatus = callback
+        C.register_friend_status(tox.handle, C.uintptr_t(tox.id))
+    })
 }
 
 // This function registers a function that executes when a friend changes their
 // status message.
 func (tox *Tox) SetOnFriendStatusMessage(callback OnFriendStatusMessage) {
-    tox.onFriendStatusMessage = callback
-    C.register_friend_status_message(tox.handle, unsafe.Pointer(tox))
+    tox.exec(nil, func() {
+        tox.onFriendStatusMessage = callback
+        C.register_friend_status_message(tox.handle, C.uintptr_t(tox.id))
+    })
 }
 
 // This function registers a function that executes when the connection status
 // of a friend changes.
 func (tox *Tox) SetOnFriendConnectionStatus(callback OnFriendConnectionStatus) {
-    tox.onFriendConnectionStatus = callback
-    C.register_friend_connection_status(tox.handle, unsafe.Pointer(tox))
+    tox.exec(nil, func() {
+        tox.onFriendConnectionStatus = callback
+        C.register_friend_connection_status(tox.handle, C.uintptr_t(tox.id))
+    })
+}
+
+// This function registers a function that executes when a friend starts or
+// stops typing.
+func (tox *Tox) SetOnFriendTyping(callback OnFriendTyping) {
+    tox.exec(nil, func() {
+        tox.onFriendTyping = callback
+        C.register_friend_typing(tox.handle, C.uintptr_t(tox.id))
+    })
 }
 
 // This function registers a function that executes when receiving a chat
 // message from a friend.
 func (tox *Tox) SetOnFriendMessage(callback OnFriendMessage) {
-    tox.onFriendMessage = callback
-    C.register_friend_message(tox.handle, unsafe.Pointer(tox))
+    tox.exec(nil, func() {
+        tox.onFriendMessage = callback
+        C.register_friend_message(tox.handle, C.uintptr_t(tox.id))
+    })
+}
+
+// This function registers a function that executes when a friend confirms
+// that a chat message was received.
+func (tox *Tox) SetOnFriendReadReceipt(callback OnFriendReadReceipt) {
+    tox.exec(nil, func() {
+        tox.onFriendReadReceipt = callback
+        C.register_friend_read_receipt(tox.handle, C.uintptr_t(tox.id))
+    })
+}
+
+// This function registers a function that executes when receiving a custom
+// lossy packet from a friend.
+func (tox *Tox) SetOnFriendLossyPacket(callback OnFriendLossyPacket) {
+    tox.exec(nil, func() {
+        tox.onFriendLossyPacket = callback
+        C.register_friend_lossy_packet(tox.handle, C.uintptr_t(tox.id))
+    })
 }
 
 // This function registers a function that executes when receiving a custom
 // loss-less packet from a friend.
 func (tox *Tox) SetOnFriendLosslessPacket(callback OnFriendLosslessPacket) {
-    tox.onFriendLosslessPacket = callback
-    C.register_friend_lossless_packet(tox.handle, unsafe.Pointer(tox))
+    tox.exec(nil, func() {
+        tox.onFriendLosslessPacket = callback
+        C.register_friend_lossless_packet(tox.handle, C.uintptr_t(tox.id))
+    })
+}
+
+// This function registers a function that executes when a friend sends a file
+// control command.
+func (tox *Tox) SetOnFileRecvControl(callback OnFileRecvControl) {
+    tox.exec(nil, func() {
+        tox.onFileRecvControl = callback
+        C.register_file_recv_control(tox.handle, C.uintptr_t(tox.id))
+    })
+}
+
+// This function registers a function that executes when the core requests the
+// next chunk of an outgoing file.
+func (tox *Tox) SetOnFileChunkRequest(callback OnFileChunkRequest) {
+    tox.exec(nil, func() {
+        tox.onFileChunkRequest = callback
+        C.register_file_chunk_request(tox.handle, C.uintptr_t(tox.id))
+    })
+}
+
+// This function registers a function that executes when a friend offers to
+// send a file.
+func (tox *Tox) SetOnFileRecv(callback OnFileRecv) {
+    tox.exec(nil, func() {
+        tox.onFileRecv = callback
+        C.register_file_recv(tox.handle, C.uintptr_t(tox.id))
+    })
+}
+
+// This function registers a function that executes when receiving a chunk of
+// an incoming file.
+func (tox *Tox) SetOnFileRecvChunk(callback OnFileRecvChunk) {
+    tox.exec(nil, func() {
+        tox.onFileRecvChunk = callback
+        C.register_file_recv_chunk(tox.handle, C.uintptr_t(tox.id))
+    })
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -275,151 +479,164 @@ func (tox *Tox) SetOnFriendLosslessPacket(callback OnFriendLosslessPacket) {
 
 // Get the address of the Tox client.
 func (tox *Tox) GetAddress() (address ToxAddress) {
-    C.tox_self_get_address(tox.handle, (*C.uint8_t)(&address[0]))
+    tox.exec(nil, func() {
+        C.tox_self_get_address(tox.handle, (*C.uint8_t)(&address[0]))
+    })
     return
 }
 
 // Get the no-spam value of the Tox client.
-func (tox *Tox) GetNoSpam() uint32 {
-    return uint32(C.tox_self_get_nospam(tox.handle))
+func (tox *Tox) GetNoSpam() (noSpam uint32) {
+    tox.exec(nil, func() {
+        noSpam = uint32(C.tox_self_get_nospam(tox.handle))
+    })
+    return
 }
 
 // Set the no-spam value of the Tox client.
 func (tox *Tox) SetNoSpam(nospam uint32) {
-    C.tox_self_set_nospam(tox.handle, C.uint32_t(nospam))
+    tox.exec(nil, func() {
+        C.tox_self_set_nospam(tox.handle, C.uint32_t(nospam))
+    })
 }
 
 // Get the public key of the Tox client.
 func (tox *Tox) GetPublicKey() (publicKey ToxPublicKey) {
-    C.tox_self_get_public_key(tox.handle, (*C.uint8_t)(&publicKey[0]))
+    tox.exec(nil, func() {
+        C.tox_self_get_public_key(tox.handle, (*C.uint8_t)(&publicKey[0]))
+    })
     return
 }
 
 // Get the secret key of the Tox client.
 func (tox *Tox) GetSecretKey() (secretKey ToxSecretKey) {
-    C.tox_self_get_secret_key(tox.handle, (*C.uint8_t)(&secretKey[0]))
+    tox.exec(nil, func() {
+        C.tox_self_get_secret_key(tox.handle, (*C.uint8_t)(&secretKey[0]))
+    })
     return
 }
 
 // Get the name of the Tox client.
 func (tox *Tox) GetName() (name []byte) {
-    var c_length = C.tox_self_get_name_size(tox.handle)
-    var c_name *C.uint8_t
-    name = make([]byte, c_length)
-    if (c_length > 0) {
-        c_name = (*C.uint8_t)(&name[0])
-    }
-    C.tox_self_get_name(tox.handle, c_name)
+    tox.exec(nil, func() {
+        var c_length = C.tox_self_get_name_size(tox.handle)
+        var c_name *C.uint8_t
+        name = make([]byte, c_length)
+        if (c_length > 0) {
+            c_name = (*C.uint8_t)(&name[0])
+        }
+        C.tox_self_get_name(tox.handle, c_name)
+    })
     return
 }
 
 // Set the name of the Tox client.
 func (tox *Tox) SetName(name []byte) (throw error) {
-    var c_length = C.size_t(len(name))
-    var c_name *C.uint8_t
-    var c_error C.TOX_ERR_SET_INFO
-    if (c_length > 0) {
-        c_name = (*C.uint8_t)(&name[0])
-    }
-    C.tox_self_set_name(tox.handle, c_name, c_length, &c_error)
-    if (c_error != C.TOX_ERR_SET_INFO_OK) {
-        switch c_error {
-            case C.TOX_ERR_SET_INFO_NULL:
-                throw = ToxErrSetInfoNull
-            case C.TOX_ERR_SET_INFO_TOO_LONG:
-                throw = ToxErrSetInfoTooLong
-            default:
-                throw = ToxErrUnknown
+    tox.exec(&throw, func() {
+        var c_length = C.size_t(len(name))
+        var c_name *C.uint8_t
+        var c_error C.TOX_ERR_SET_INFO
+        if (c_length > 0) {
+            c_name = (*C.uint8_t)(&name[0])
         }
-    }
+        C.tox_self_set_name(tox.handle, c_name, c_length, &c_error)
+        if (c_error != C.TOX_ERR_SET_INFO_OK) {
+            throw = errorSetInfo("tox_self_set_name", c_error)
+        }
+    })
     return
 }
 
 // Get the status of the Tox client.
 func (tox *Tox) GetStatus() (userStatus ToxUserStatus) {
-    var c_user_status = C.tox_self_get_status(tox.handle)
-    switch c_user_status {
-        case C.TOX_USER_STATUS_AWAY:
-            userStatus = ToxUserStatusAway
-        case C.TOX_USER_STATUS_BUSY:
-            userStatus = ToxUserStatusBusy
-        default:
-            userStatus = ToxUserStatusNone
-    }
+    tox.exec(nil, func() {
+        var c_user_status = C.tox_self_get_status(tox.handle)
+        switch c_user_status {
+            case C.TOX_USER_STATUS_AWAY:
+                userStatus = ToxUserStatusAway
+            case C.TOX_USER_STATUS_BUSY:
+                userStatus = ToxUserStatusBusy
+            default:
+                userStatus = ToxUserStatusNone
+        }
+    })
     return
 }
 
 // Set the status of the Tox client.
 func (tox *Tox) SetStatus(userStatus ToxUserStatus) {
-    var c_user_status C.TOX_USER_STATUS
-    switch userStatus {
-        case ToxUserStatusAway:
-            c_user_status = C.TOX_USER_STATUS_AWAY
-        case ToxUserStatusBusy:
-            c_user_status = C.TOX_USER_STATUS_BUSY
-        default:
-            c_user_status = C.TOX_USER_STATUS_NONE
-    }
-    C.tox_self_set_status(tox.handle, c_user_status)
+    tox.exec(nil, func() {
+        var c_user_status C.TOX_USER_STATUS
+        switch userStatus {
+            case ToxUserStatusAway:
+                c_user_status = C.TOX_USER_STATUS_AWAY
+            case ToxUserStatusBusy:
+                c_user_status = C.TOX_USER_STATUS_BUSY
+            default:
+                c_user_status = C.TOX_USER_STATUS_NONE
+        }
+        C.tox_self_set_status(tox.handle, c_user_status)
+    })
 }
 
 // Get the status message of the Tox client.
 func (tox *Tox) GetStatusMessage() (message []byte) {
-    var c_length = C.tox_self_get_status_message_size(tox.handle)
-    var c_message *C.uint8_t
-    message = make([]byte, c_length)
-    if (c_length > 0) {
-        c_message = (*C.uint8_t)(&message[0])
-    }
-    C.tox_self_get_status_message(tox.handle, c_message)
+    tox.exec(nil, func() {
+        var c_length = C.tox_self_get_status_message_size(tox.handle)
+        var c_message *C.uint8_t
+        message = make([]byte, c_length)
+        if (c_length > 0) {
+            c_message = (*C.uint8_t)(&message[0])
+        }
+        C.tox_self_get_status_message(tox.handle, c_message)
+    })
     return
 }
 
 // Set the status message of the Tox client.
 func (tox *Tox) SetStatusMessage(message []byte) (throw error) {
-    var c_length = C.size_t(len(message))
-    var c_message *C.uint8_t
-    var c_error C.TOX_ERR_SET_INFO
-    if (c_length > 0) {
-        c_message = (*C.uint8_t)(&message[0])
-    }
-    C.tox_self_set_status_message(tox.handle, c_message, c_length, &c_error)
-    if (c_error != C.TOX_ERR_SET_INFO_OK) {
-        switch c_error {
-            case C.TOX_ERR_SET_INFO_NULL:
-                throw = ToxErrSetInfoNull
-            case C.TOX_ERR_SET_INFO_TOO_LONG:
-                throw = ToxErrSetInfoTooLong
-            default:
-                throw = ToxErrUnknown
+    tox.exec(&throw, func() {
+        var c_length = C.size_t(len(message))
+        var c_message *C.uint8_t
+        var c_error C.TOX_ERR_SET_INFO
+        if (c_length > 0) {
+            c_message = (*C.uint8_t)(&message[0])
         }
-    }
+        C.tox_self_set_status_message(tox.handle, c_message, c_length, &c_error)
+        if (c_error != C.TOX_ERR_SET_INFO_OK) {
+            throw = errorSetInfo("tox_self_set_status_message", c_error)
+        }
+    })
     return
 }
 
 // Get the connection status of the Tox client.
 func (tox *Tox) GetConnectionStatus() (connectionStatus ToxConnectionStatus) {
-    var c_connection_status = C.tox_self_get_connection_status(tox.handle)
-    switch c_connection_status {
-        case C.TOX_CONNECTION_TCP:
-            connectionStatus = ToxConnectionTCP
-        case C.TOX_CONNECTION_UDP:
-            connectionStatus = ToxConnectionUDP
-        default:
-            connectionStatus = ToxConnectionNone
-    }
+    tox.exec(nil, func() {
+        var c_connection_status = C.tox_self_get_connection_status(tox.handle)
+        switch c_connection_status {
+            case C.TOX_CONNECTION_TCP:
+                connectionStatus = ToxConnectionTCP
+            case C.TOX_CONNECTION_UDP:
+                connectionStatus = ToxConnectionUDP
+            default:
+                connectionStatus = ToxConnectionNone
+        }
+    })
     return
 }
 
 // Get the friend list of the Tox client.
 func (tox *Tox) GetFriendList() (friendList []uint32) {
-    var c_length = C.tox_self_get_friend_list_size(tox.handle)
-    var c_friend_list *C.uint32_t
-    friendList = make([]uint32, c_length)
-    if (c_length > 0) {
-        c_friend_list = (*C.uint32_t)(&friendList[0])
-    }
-    C.tox_self_get_friend_list(tox.handle, c_friend_list)
+    tox.exec(nil, func() {
+        var c_length = C.tox_self_get_friend_list_size(tox.handle)
+        var c_friend_list *C.uint32_t
+        friendList = make([]uint32, c_length)
+        if (c_length > 0) {
+            c_friend_list = (*C.uint32_t)(&friendList[0])
+        }
+        C.tox_self_get_friend_list(tox.handle, c_friend_list)
+    })
     return
 }
 
@@ -429,93 +646,61 @@ func (tox *Tox) GetFriendList() (friendList []uint32) {
 
 // Add a friend.
 func (tox *Tox) FriendAdd(address ToxAddress, message []byte) (friendNumber uint32, throw error) {
-    var c_address = (*C.uint8_t)(&address[0])
-    var c_length = C.size_t(len(message))
-    var c_message *C.uint8_t
-    var c_error C.TOX_ERR_FRIEND_ADD
-    if (c_length > 0) {
-        c_message = (*C.uint8_t)(&message[0])
-    }
-    var c_friend_number = C.tox_friend_add(tox.handle, c_address, c_message, c_length, &c_error)
-    if (c_error != C.TOX_ERR_FRIEND_ADD_OK) {
-        switch c_error {
-            case C.TOX_ERR_FRIEND_ADD_NULL:
-                throw = ToxErrFriendAddNull
-            case C.TOX_ERR_FRIEND_ADD_TOO_LONG:
-                throw = ToxErrFriendAddTooLong
-            case C.TOX_ERR_FRIEND_ADD_NO_MESSAGE:
-                throw = ToxErrFriendAddNoMessage
-            case C.TOX_ERR_FRIEND_ADD_OWN_KEY:
-                throw = ToxErrFriendAddOwnKey
-            case C.TOX_ERR_FRIEND_ADD_ALREADY_SENT:
-                throw = ToxErrFriendAddAlreadySent
-            case C.TOX_ERR_FRIEND_ADD_BAD_CHECKSUM:
-                throw = ToxErrFriendAddBadChecksum
-            case C.TOX_ERR_FRIEND_ADD_SET_NEW_NOSPAM:
-                throw = ToxErrFriendAddSetNewNoSpam
-            case C.TOX_ERR_FRIEND_ADD_MALLOC:
-                throw = ToxErrFriendAddMalloc
-            default:
-                throw = ToxErrUnknown
+    defer tox.wakeup()
+    tox.exec(&throw, func() {
+        var c_address = (*C.uint8_t)(&address[0])
+        var c_length = C.size_t(len(message))
+        var c_message *C.uint8_t
+        var c_error C.TOX_ERR_FRIEND_ADD
+        if (c_length > 0) {
+            c_message = (*C.uint8_t)(&message[0])
         }
-    } else {
-        friendNumber = uint32(c_friend_number)
-    }
+        var c_friend_number = C.tox_friend_add(tox.handle, c_address, c_message, c_length, &c_error)
+        if (c_error != C.TOX_ERR_FRIEND_ADD_OK) {
+            throw = errorFriendAdd("tox_friend_add", c_error)
+        } else {
+            friendNumber = uint32(c_friend_number)
+        }
+    })
     return
 }
 
 // Add a friend without sending a friend request.
 func (tox *Tox) FriendAddNoRequest(publicKey ToxPublicKey) (friendNumber uint32, throw error) {
-    var c_public_key = (*C.uint8_t)(&publicKey[0])
-    var c_error C.TOX_ERR_FRIEND_ADD
-    var c_friend_number = C.tox_friend_add_norequest(tox.handle, c_public_key, &c_error)
-    if (c_error != C.TOX_ERR_FRIEND_ADD_OK) {
-        switch c_error {
-            case C.TOX_ERR_FRIEND_ADD_NULL:
-                throw = ToxErrFriendAddNull
-            case C.TOX_ERR_FRIEND_ADD_TOO_LONG:
-                throw = ToxErrFriendAddTooLong
-            case C.TOX_ERR_FRIEND_ADD_NO_MESSAGE:
-                throw = ToxErrFriendAddNoMessage
-            case C.TOX_ERR_FRIEND_ADD_OWN_KEY:
-                throw = ToxErrFriendAddOwnKey
-            case C.TOX_ERR_FRIEND_ADD_ALREADY_SENT:
-                throw = ToxErrFriendAddAlreadySent
-            case C.TOX_ERR_FRIEND_ADD_BAD_CHECKSUM:
-                throw = ToxErrFriendAddBadChecksum
-            case C.TOX_ERR_FRIEND_ADD_SET_NEW_NOSPAM:
-                throw = ToxErrFriendAddSetNewNoSpam
-            case C.TOX_ERR_FRIEND_ADD_MALLOC:
-                throw = ToxErrFriendAddMalloc
-            default:
-                throw = ToxErrUnknown
+    defer tox.wakeup()
+    tox.exec(&throw, func() {
+        var c_public_key = (*C.uint8_t)(&publicKey[0])
+        var c_error C.TOX_ERR_FRIEND_ADD
+        var c_friend_number = C.tox_friend_add_norequest(tox.handle, c_public_key, &c_error)
+        if (c_error != C.TOX_ERR_FRIEND_ADD_OK) {
+            throw = errorFriendAdd("tox_friend_add_norequest", c_error)
+        } else {
+            friendNumber = uint32(c_friend_number)
         }
-    } else {
-        friendNumber = uint32(c_friend_number)
-    }
+    })
     return
 }
 
 // Delete a friend.
 func (tox *Tox) FriendDelete(friendNumber uint32) (throw error) {
-    var c_friend_number = C.uint32_t(friendNumber)
-    var c_error C.TOX_ERR_FRIEND_DELETE
-    C.tox_friend_delete(tox.handle, c_friend_number, &c_error)
-    if (c_error != C.TOX_ERR_FRIEND_DELETE_OK) {
-        switch c_error {
-            case C.TOX_ERR_FRIEND_DELETE_FRIEND_NOT_FOUND:
-                throw = ToxErrFriendDeleteFriendNotFound
-            default:
-                throw = ToxErrUnknown
+    tox.exec(&throw, func() {
+        var c_friend_number = C.uint32_t(friendNumber)
+        var c_error C.TOX_ERR_FRIEND_DELETE
+        C.tox_friend_delete(tox.handle, c_friend_number, &c_error)
+        if (c_error != C.TOX_ERR_FRIEND_DELETE_OK) {
+            throw = errorFriendDelete("tox_friend_delete", c_error)
         }
-    }
+    })
     return
 }
 
 // Check if a friend exists.
-func (tox *Tox) FriendExists(friendNumber uint32) bool {
-    var c_friend_number = C.uint32_t(friendNumber)
-    return bool(C.tox_friend_exists(tox.handle, c_friend_number))
+func (tox *Tox) FriendExists(friendNumber uint32) (exists bool) {
+    tox.exec(nil, func() {
+        var c_friend_number = C.uint32_t(friendNumber)
+        exists = bool(C.tox_friend_exists(tox.handle, c_friend_number))
+    })
+    return
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -524,182 +709,152 @@ func (tox *Tox) FriendExists(friendNumber uint32) bool {
 
 // Get the name of a friend.
 func (tox *Tox) FriendGetName(friendNumber uint32) (name []byte, throw error) {
-    var c_friend_number = C.uint32_t(friendNumber)
-    var c_error C.TOX_ERR_FRIEND_QUERY
-    var c_length = C.tox_friend_get_name_size(tox.handle, c_friend_number, &c_error)
-    if (c_error != C.TOX_ERR_FRIEND_QUERY_OK) {
-        switch c_error {
-            case C.TOX_ERR_FRIEND_QUERY_NULL:
-                throw = ToxErrFriendQueryNull
-            case C.TOX_ERR_FRIEND_QUERY_FRIEND_NOT_FOUND:
-                throw = ToxErrFriendQueryFriendNotFound
-            default:
-                throw = ToxErrUnknown
-        }
-    } else {
-        var c_name *C.uint8_t
-        name = make([]byte, c_length)
-        if (c_length > 0) {
-            c_name = (*C.uint8_t)(&name[0])
-        }
-        C.tox_friend_get_name(tox.handle, c_friend_number, c_name, &c_error)
+    tox.exec(&throw, func() {
+        var c_friend_number = C.uint32_t(friendNumber)
+        var c_error C.TOX_ERR_FRIEND_QUERY
+        var c_length = C.tox_friend_get_name_size(tox.handle, c_friend_number, &c_error)
         if (c_error != C.TOX_ERR_FRIEND_QUERY_OK) {
-            name = nil
-            switch c_error {
-                case C.TOX_ERR_FRIEND_QUERY_NULL:
-                    throw = ToxErrFriendQueryNull
-                case C.TOX_ERR_FRIEND_QUERY_FRIEND_NOT_FOUND:
-                    throw = ToxErrFriendQueryFriendNotFound
-                default:
-                    throw = ToxErrUnknown
+            throw = errorFriendQuery("tox_friend_get_name_size", c_error)
+        } else {
+            var c_name *C.uint8_t
+            name = make([]byte, c_length)
+            if (c_length > 0) {
+                c_name = (*C.uint8_t)(&name[0])
+            }
+            C.tox_friend_get_name(tox.handle, c_friend_number, c_name, &c_error)
+            if (c_error != C.TOX_ERR_FRIEND_QUERY_OK) {
+                name = nil
+                throw = errorFriendQuery("tox_friend_get_name", c_error)
             }
         }
-    }
+    })
     return
 }
 
 // Get the public key of a friend.
 func (tox *Tox) FriendGetPublicKey(friendNumber uint32) (publicKey ToxPublicKey, throw error) {
-    var c_friend_number = C.uint32_t(friendNumber)
-    var c_public_key = (*C.uint8_t)(&publicKey[0])
-    var c_error C.TOX_ERR_FRIEND_GET_PUBLIC_KEY
-    C.tox_friend_get_public_key(tox.handle, c_friend_number, c_public_key, &c_error)
-    if (c_error != C.TOX_ERR_FRIEND_GET_PUBLIC_KEY_OK) {
-        switch c_error {
-            case C.TOX_ERR_FRIEND_GET_PUBLIC_KEY_FRIEND_NOT_FOUND:
-                throw = ToxErrFriendGetPublicKeyFriendNotFound
-            default:
-                throw = ToxErrUnknown
+    tox.exec(&throw, func() {
+        var c_friend_number = C.uint32_t(friendNumber)
+        var c_public_key = (*C.uint8_t)(&publicKey[0])
+        var c_error C.TOX_ERR_FRIEND_GET_PUBLIC_KEY
+        C.tox_friend_get_public_key(tox.handle, c_friend_number, c_public_key, &c_error)
+        if (c_error != C.TOX_ERR_FRIEND_GET_PUBLIC_KEY_OK) {
+            throw = errorFriendGetPublicKey("tox_friend_get_public_key", c_error)
         }
-    }
+    })
     return
 }
 
 // Get the friend associated with the given public key.
 func (tox *Tox) FriendByPublicKey(publicKey ToxPublicKey) (friendNumber uint32, throw error) {
-    var c_public_key = (*C.uint8_t)(&publicKey[0])
-    var c_error C.TOX_ERR_FRIEND_BY_PUBLIC_KEY
-    var c_friend_number = C.tox_friend_by_public_key(tox.handle, c_public_key, &c_error)
-    if (c_error != C.TOX_ERR_FRIEND_BY_PUBLIC_KEY_OK) {
-        switch c_error {
-            case C.TOX_ERR_FRIEND_BY_PUBLIC_KEY_NULL:
-                throw = ToxErrFriendByPublicKeyNull
-            case C.TOX_ERR_FRIEND_BY_PUBLIC_KEY_NOT_FOUND:
-                throw = ToxErrFriendByPublicKeyNotFound
-            default:
-                throw = ToxErrUnknown
+    tox.exec(&throw, func() {
+        var c_public_key = (*C.uint8_t)(&publicKey[0])
+        var c_error C.TOX_ERR_FRIEND_BY_PUBLIC_KEY
+        var c_friend_number = C.tox_friend_by_public_key(tox.handle, c_public_key, &c_error)
+        if (c_error != C.TOX_ERR_FRIEND_BY_PUBLIC_KEY_OK) {
+            throw = errorFriendByPublicKey("tox_friend_by_public_key", c_error)
+        } else {
+            friendNumber = uint32(c_friend_number)
         }
-    } else {
-        friendNumber = uint32(c_friend_number)
-    }
+    })
     return
 }
 
 // Get the status of a friend.
 func (tox *Tox) FriendGetStatus(friendNumber uint32) (userStatus ToxUserStatus, throw error) {
-    var c_friend_number = C.uint32_t(friendNumber)
-    var c_error C.TOX_ERR_FRIEND_QUERY
-    var c_status = C.tox_friend_get_status(tox.handle, c_friend_number, &c_error)
-    if (c_error != C.TOX_ERR_FRIEND_QUERY_OK) {
-        switch c_error {
-            case C.TOX_ERR_FRIEND_QUERY_NULL:
-                throw = ToxErrFriendQueryNull
-            case C.TOX_ERR_FRIEND_QUERY_FRIEND_NOT_FOUND:
-                throw = ToxErrFriendQueryFriendNotFound
-            default:
-                throw = ToxErrUnknown
-        }
-    } else {
-        switch c_status {
-            case C.TOX_USER_STATUS_AWAY:
-                userStatus = ToxUserStatusAway
-            case C.TOX_USER_STATUS_BUSY:
-                userStatus = ToxUserStatusBusy
-            default:
-                userStatus = ToxUserStatusNone
+    tox.exec(&throw, func() {
+        var c_friend_number = C.uint32_t(friendNumber)
+        var c_error C.TOX_ERR_FRIEND_QUERY
+        var c_status = C.tox_friend_get_status(tox.handle, c_friend_number, &c_error)
+        if (c_error != C.TOX_ERR_FRIEND_QUERY_OK) {
+            throw = errorFriendQuery("tox_friend_get_status", c_error)
+        } else {
+            switch c_status {
+                case C.TOX_USER_STATUS_AWAY:
+                    userStatus = ToxUserStatusAway
+                case C.TOX_USER_STATUS_BUSY:
+                    userStatus = ToxUserStatusBusy
+                default:
+                    userStatus = ToxUserStatusNone
+            }
         }
-    }
+    })
     return
 }
 
 // Get the status message of a friend.
 func (tox *Tox) FriendGetStatusMessage(friendNumber uint32) (message []byte, throw error) {
-    var c_friend_number = C.uint32_t(friendNumber)
-    var c_error C.TOX_ERR_FRIEND_QUERY
-    var c_length = C.tox_friend_get_status_message_size(tox.handle, c_friend_number, &c_error)
-    if (c_error != C.TOX_ERR_FRIEND_QUERY_OK) {
-        switch c_error {
-            case C.TOX_ERR_FRIEND_QUERY_NULL:
-                throw = ToxErrFriendQueryNull
-            case C.TOX_ERR_FRIEND_QUERY_FRIEND_NOT_FOUND:
-                throw = ToxErrFriendQueryFriendNotFound
-            default:
-                throw = ToxErrUnknown
-        }
-    } else {
-        var c_message *C.uint8_t
-        message = make([]byte, c_length)
-        if (c_length > 0) {
-            c_message = (*C.uint8_t)(&message[0])
-        }
-        C.tox_friend_get_status_message(tox.handle, c_friend_number, c_message, &c_error)
+    tox.exec(&throw, func() {
+        var c_friend_number = C.uint32_t(friendNumber)
+        var c_error C.TOX_ERR_FRIEND_QUERY
+        var c_length = C.tox_friend_get_status_message_size(tox.handle, c_friend_number, &c_error)
         if (c_error != C.TOX_ERR_FRIEND_QUERY_OK) {
-            message = nil
-            switch c_error {
-                case C.TOX_ERR_FRIEND_QUERY_NULL:
-                    throw = ToxErrFriendQueryNull
-                case C.TOX_ERR_FRIEND_QUERY_FRIEND_NOT_FOUND:
-                    throw = ToxErrFriendQueryFriendNotFound
-                default:
-                    throw = ToxErrUnknown
+            throw = errorFriendQuery("tox_friend_get_status_message_size", c_error)
+        } else {
+            var c_message *C.uint8_t
+            message = make([]byte, c_length)
+            if (c_length > 0) {
+                c_message = (*C.uint8_t)(&message[0])
+            }
+            C.tox_friend_get_status_message(tox.handle, c_friend_number, c_message, &c_error)
+            if (c_error != C.TOX_ERR_FRIEND_QUERY_OK) {
+                message = nil
+                throw = errorFriendQuery("tox_friend_get_status_message", c_error)
             }
         }
-    }
+    })
     return
 }
 
 // Get the connection status of a friend.
 func (tox *Tox) FriendGetConnectionStatus(friendNumber uint32) (connectionStatus ToxConnectionStatus, throw error) {
-    var c_friend_number = C.uint32_t(friendNumber)
-    var c_error C.TOX_ERR_FRIEND_QUERY
-    var c_connection_status = C.tox_friend_get_connection_status(tox.handle, c_friend_number, &c_error)
-    if (c_error != C.TOX_ERR_FRIEND_QUERY_OK) {
-        switch c_error {
-            case C.TOX_ERR_FRIEND_QUERY_NULL:
-                throw = ToxErrFriendQueryNull
-            case C.TOX_ERR_FRIEND_QUERY_FRIEND_NOT_FOUND:
-                throw = ToxErrFriendQueryFriendNotFound
-            default:
-                throw = ToxErrUnknown
-        }
-    } else {
-        switch c_connection_status {
-            case C.TOX_CONNECTION_TCP:
-                connectionStatus = ToxConnectionTCP
-            case C.TOX_CONNECTION_UDP:
-                connectionStatus = ToxConnectionUDP
-            default:
-                connectionStatus = ToxConnectionNone
+    tox.exec(&throw, func() {
+        var c_friend_number = C.uint32_t(friendNumber)
+        var c_error C.TOX_ERR_FRIEND_QUERY
+        var c_connection_status = C.tox_friend_get_connection_status(tox.handle, c_friend_number, &c_error)
+        if (c_error != C.TOX_ERR_FRIEND_QUERY_OK) {
+            throw = errorFriendQuery("tox_friend_get_connection_status", c_error)
+        } else {
+            switch c_connection_status {
+                case C.TOX_CONNECTION_TCP:
+                    connectionStatus = ToxConnectionTCP
+                case C.TOX_CONNECTION_UDP:
+                    connectionStatus = ToxConnectionUDP
+                default:
+                    connectionStatus = ToxConnectionNone
+            }
         }
-    }
+    })
     return
 }
 
 // Get the last time a friend was seen online.
 func (tox *Tox) FriendGetLastOnline(friendNumber uint32) (timestamp time.Time, throw error) {
-    var c_friend_number = C.uint32_t(friendNumber)
-    var c_error C.TOX_ERR_FRIEND_GET_LAST_ONLINE
-    var c_timestamp = C.tox_friend_get_last_online(tox.handle, c_friend_number, &c_error)
-    if (c_error != C.TOX_ERR_FRIEND_GET_LAST_ONLINE_OK) {
-        switch c_error {
-            case C.TOX_ERR_FRIEND_GET_LAST_ONLINE_FRIEND_NOT_FOUND:
-                throw = ToxErrFriendGetLastOnlineFriendNotFound
-            default:
-                throw = ToxErrUnknown
+    tox.exec(&throw, func() {
+        var c_friend_number = C.uint32_t(friendNumber)
+        var c_error C.TOX_ERR_FRIEND_GET_LAST_ONLINE
+        var c_timestamp = C.tox_friend_get_last_online(tox.handle, c_friend_number, &c_error)
+        if (c_error != C.TOX_ERR_FRIEND_GET_LAST_ONLINE_OK) {
+            throw = errorFriendGetLastOnline("tox_friend_get_last_online", c_error)
+        } else {
+            timestamp = time.Unix(int64(c_timestamp), 0)
         }
-    } else {
-        timestamp = time.Unix(int64(c_timestamp), 0)
-    }
+    })
+    return
+}
+
+// Check if a friend is currently typing a message.
+func (tox *Tox) FriendGetTyping(friendNumber uint32) (isTyping bool, throw error) {
+    tox.exec(&throw, func() {
+        var c_friend_number = C.uint32_t(friendNumber)
+        var c_error C.TOX_ERR_FRIEND_QUERY
+        var c_is_typing = C.tox_friend_get_typing(tox.handle, c_friend_number, &c_error)
+        if (c_error != C.TOX_ERR_FRIEND_QUERY_OK) {
+            throw = errorFriendQuery("tox_friend_get_typing", c_error)
+        } else {
+            isTyping = bool(c_is_typing)
+        }
+    })
     return
 }
 
@@ -707,76 +862,214 @@ func (tox *Tox) FriendGetLastOnline(friendNumber uint32) (timestamp time.Time, t
 ////////////////////////////// DATA TRANSMISSION ///////////////////////////////
 ////////////////////////////////////////////////////////////////////////////////
 
+// Set the typing status of the client for a friend. The status is sent to the
+// friend when they are online, and remains set until it is cleared.
+func (tox *Tox) SetTyping(friendNumber uint32, isTyping bool) (throw error) {
+    defer tox.wakeup()
+    tox.exec(&throw, func() {
+        var c_friend_number = C.uint32_t(friendNumber)
+        var c_error C.TOX_ERR_SET_TYPING
+        C.tox_self_set_typing(tox.handle, c_friend_number, C.bool(isTyping), &c_error)
+        if (c_error != C.TOX_ERR_SET_TYPING_OK) {
+            throw = errorSetTyping("tox_self_set_typing", c_error)
+        }
+    })
+    return
+}
+
 // Send a chat message to an online friend.
 func (tox *Tox) FriendSendMessage(friendNumber uint32, messageType ToxMessageType, message []byte) (messageId uint32, throw error) {
-    var c_friend_number = C.uint32_t(friendNumber)
-    var c_message_type C.TOX_MESSAGE_TYPE
-    var c_length = C.size_t(len(message))
-    var c_message *C.uint8_t
-    var c_error C.TOX_ERR_FRIEND_SEND_MESSAGE
-    switch messageType {
-        case ToxMessageTypeAction:
-            c_message_type = C.TOX_MESSAGE_TYPE_ACTION
-        default:
-            c_message_type = C.TOX_MESSAGE_TYPE_NORMAL
-    }
-    if (c_length > 0) {
-        c_message = (*C.uint8_t)(&message[0])
-    }
-    var c_message_id = C.tox_friend_send_message(tox.handle, c_friend_number, c_message_type, c_message, c_length, &c_error)
-    if (c_error != C.TOX_ERR_FRIEND_SEND_MESSAGE_OK) {
-        switch c_error {
-            case C.TOX_ERR_FRIEND_SEND_MESSAGE_NULL:
-                throw = ToxErrFriendSendMessageNull
-            case C.TOX_ERR_FRIEND_SEND_MESSAGE_FRIEND_NOT_FOUND:
-                throw = ToxErrFriendSendMessageFriendNotFound
-            case C.TOX_ERR_FRIEND_SEND_MESSAGE_FRIEND_NOT_CONNECTED:
-                throw = ToxErrFriendSendMessageFriendNotConnected
-            case C.TOX_ERR_FRIEND_SEND_MESSAGE_SENDQ:
-                throw = ToxErrFriendSendMessageSendQ
-            case C.TOX_ERR_FRIEND_SEND_MESSAGE_TOO_LONG:
-                throw = ToxErrFriendSendMessageTooLong
-            case C.TOX_ERR_FRIEND_SEND_MESSAGE_EMPTY:
-                throw = ToxErrFriendSendMessageEmpty
+    defer tox.wakeup()
+    tox.exec(&throw, func() {
+        var c_friend_number = C.uint32_t(friendNumber)
+        var c_message_type C.TOX_MESSAGE_TYPE
+        var c_length = C.size_t(len(message))
+        var c_message *C.uint8_t
+        var c_error C.TOX_ERR_FRIEND_SEND_MESSAGE
+        switch messageType {
+            case ToxMessageTypeAction:
+                c_message_type = C.TOX_MESSAGE_TYPE_ACTION
             default:
-                throw = ToxErrUnknown
+                c_message_type = C.TOX_MESSAGE_TYPE_NORMAL
         }
-    } else {
-        messageId = uint32(c_message_id)
+        if (c_length > 0) {
+            c_message = (*C.uint8_t)(&message[0])
+        }
+        var c_message_id = C.tox_friend_send_message(tox.handle, c_friend_number, c_message_type, c_message, c_length, &c_error)
+        if (c_error != C.TOX_ERR_FRIEND_SEND_MESSAGE_OK) {
+            throw = errorFriendSendMessage("tox_friend_send_message", c_error)
+        } else {
+            messageId = uint32(c_message_id)
+        }
+    })
+    return
+}
+
+// The inclusive range of the first byte of a custom lossy packet.
+const (
+
+    lossyPacketRangeStart = 200
+    lossyPacketRangeEnd   = 254
+
+)
+
+// Send a custom lossy packet to an online friend. The first byte of the packet
+// must be in the range 200-254. Lossy packets are not retransmitted, so they
+// may be lost or arrive out of order.
+func (tox *Tox) FriendSendLossyPacket(friendNumber uint32, data []byte) (throw error) {
+    if (len(data) == 0) {
+        return ToxErrFriendCustomPacketEmpty
+    }
+    if (data[0] < lossyPacketRangeStart || data[0] > lossyPacketRangeEnd) {
+        return ToxErrFriendCustomPacketInvalid
     }
+    defer tox.wakeup()
+    tox.exec(&throw, func() {
+        var c_friend_number = C.uint32_t(friendNumber)
+        var c_length = C.size_t(len(data))
+        var c_data = (*C.uint8_t)(&data[0])
+        var c_error C.TOX_ERR_FRIEND_CUSTOM_PACKET
+        C.tox_friend_send_lossy_packet(tox.handle, c_friend_number, c_data, c_length, &c_error)
+        if (c_error != C.TOX_ERR_FRIEND_CUSTOM_PACKET_OK) {
+            throw = errorFriendCustomPacket("tox_friend_send_lossy_packet", c_error)
+        }
+    })
     return
 }
 
 // Send a custom loss-less packet to an online friend.
 func (tox *Tox) FriendSendLosslessPacket(friendNumber uint32, data []byte) (throw error) {
+    defer tox.wakeup()
+    tox.exec(&throw, func() {
+        var c_friend_number = C.uint32_t(friendNumber)
+        var c_length = C.size_t(len(data))
+        var c_data *C.uint8_t
+        var c_error C.TOX_ERR_FRIEND_CUSTOM_PACKET
+        if (c_length > 0) {
+            c_data = (*C.uint8_t)(&data[0])
+        }
+        C.tox_friend_send_lossless_packet(tox.handle, c_friend_number, c_data, c_length, &c_error)
+        if (c_error != C.TOX_ERR_FRIEND_CUSTOM_PACKET_OK) {
+            throw = errorFriendCustomPacket("tox_friend_send_lossless_packet", c_error)
+        }
+    })
+    return
+}
+
+////////////////////////////////////////////////////////////////////////////////
+//////////////////////////////// FILE TRANSFER /////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Send a file control command to a friend for the given file transfer. An
+// incoming transfer is accepted by sending ToxFileControlResume.
+func (tox *Tox) FileControl(friendNumber uint32, fileNumber uint32, control ToxFileControl) (throw error) {
     var c_friend_number = C.uint32_t(friendNumber)
-    var c_length = C.size_t(len(data))
-    var c_data *C.uint8_t
-    var c_error C.TOX_ERR_FRIEND_CUSTOM_PACKET
-    if (c_length > 0) {
-        c_data = (*C.uint8_t)(&data[0])
+    var c_file_number = C.uint32_t(fileNumber)
+    var c_control C.TOX_FILE_CONTROL
+    var c_error C.TOX_ERR_FILE_CONTROL
+    switch control {
+        case ToxFileControlResume:
+            c_control = C.TOX_FILE_CONTROL_RESUME
+        case ToxFileControlPause:
+            c_control = C.TOX_FILE_CONTROL_PAUSE
+        case ToxFileControlCancel:
+            c_control = C.TOX_FILE_CONTROL_CANCEL
+        default:
+            return errors.New("unknown file control")
     }
-    C.tox_friend_send_lossless_packet(tox.handle, c_friend_number, c_data, c_length, &c_error)
-    if (c_error != C.TOX_ERR_FRIEND_CUSTOM_PACKET_OK) {
-        switch c_error {
-            case C.TOX_ERR_FRIEND_CUSTOM_PACKET_NULL:
-                throw = ToxErrFriendCustomPacketNull
-            case C.TOX_ERR_FRIEND_CUSTOM_PACKET_FRIEND_NOT_FOUND:
-                throw = ToxErrFriendCustomPacketFriendNotFound
-            case C.TOX_ERR_FRIEND_CUSTOM_PACKET_FRIEND_NOT_CONNECTED:
-                throw = ToxErrFriendCustomPacketFriendNotConnected
-            case C.TOX_ERR_FRIEND_CUSTOM_PACKET_INVALID:
-                throw = ToxErrFriendCustomPacketInvalid
-            case C.TOX_ERR_FRIEND_CUSTOM_PACKET_EMPTY:
-                throw = ToxErrFriendCustomPacketEmpty
-            case C.TOX_ERR_FRIEND_CUSTOM_PACKET_TOO_LONG:
-                throw = ToxErrFriendCustomPacketTooLong
-            case C.TOX_ERR_FRIEND_CUSTOM_PACKET_SENDQ:
-                throw = ToxErrFriendCustomPacketSendQ
-            default:
-                throw = ToxErrUnknown
+    defer tox.wakeup()
+    tox.exec(&throw, func() {
+        C.tox_file_control(tox.handle, c_friend_number, c_file_number, c_control, &c_error)
+        if (c_error != C.TOX_ERR_FILE_CONTROL_OK) {
+            throw = errorFileControl("tox_file_control", c_error)
         }
-    }
+    })
+    return
+}
+
+// Send a file seek command to a friend for the given incoming file transfer.
+// This can only be done before the transfer is accepted, and is used to resume
+// a transfer from a known position.
+func (tox *Tox) FileSeek(friendNumber uint32, fileNumber uint32, position uint64) (throw error) {
+    defer tox.wakeup()
+    tox.exec(&throw, func() {
+        var c_friend_number = C.uint32_t(friendNumber)
+        var c_file_number = C.uint32_t(fileNumber)
+        var c_position = C.uint64_t(position)
+        var c_error C.TOX_ERR_FILE_SEEK
+        C.tox_file_seek(tox.handle, c_friend_number, c_file_number, c_position, &c_error)
+        if (c_error != C.TOX_ERR_FILE_SEEK_OK) {
+            throw = errorFileSeek("tox_file_seek", c_error)
+        }
+    })
+    return
+}
+
+// Get the file identifier associated with a file transfer. The identifier is
+// stable across reconnections and can be used to resume a broken transfer.
+func (tox *Tox) FileGetFileId(friendNumber uint32, fileNumber uint32) (fileId ToxFileId, throw error) {
+    tox.exec(&throw, func() {
+        var c_friend_number = C.uint32_t(friendNumber)
+        var c_file_number = C.uint32_t(fileNumber)
+        var c_file_id = (*C.uint8_t)(&fileId[0])
+        var c_error C.TOX_ERR_FILE_GET
+        C.tox_file_get_file_id(tox.handle, c_friend_number, c_file_number, c_file_id, &c_error)
+        if (c_error != C.TOX_ERR_FILE_GET_OK) {
+            throw = errorFileGet("tox_file_get_file_id", c_error)
+        }
+    })
+    return
+}
+
+// Offer to send a file to a friend. If the file identifier is nil, then a
+// random one is generated by the core. The file size may be set to the maximum
+// uint64 value to indicate a stream of unknown length.
+func (tox *Tox) FileSend(friendNumber uint32, kind ToxFileKind, fileSize uint64, fileId *ToxFileId, filename []byte) (fileNumber uint32, throw error) {
+    defer tox.wakeup()
+    tox.exec(&throw, func() {
+        var c_friend_number = C.uint32_t(friendNumber)
+        var c_kind = C.uint32_t(kind)
+        var c_file_size = C.uint64_t(fileSize)
+        var c_file_id *C.uint8_t
+        var c_length = C.size_t(len(filename))
+        var c_filename *C.uint8_t
+        var c_error C.TOX_ERR_FILE_SEND
+        if (fileId != nil) {
+            c_file_id = (*C.uint8_t)(&fileId[0])
+        }
+        if (c_length > 0) {
+            c_filename = (*C.uint8_t)(&filename[0])
+        }
+        var c_file_number = C.tox_file_send(tox.handle, c_friend_number, c_kind, c_file_size, c_file_id, c_filename, c_length, &c_error)
+        if (c_error != C.TOX_ERR_FILE_SEND_OK) {
+            throw = errorFileSend("tox_file_send", c_error)
+        } else {
+            fileNumber = uint32(c_file_number)
+        }
+    })
+    return
+}
+
+// Send a chunk of file data to a friend. This should be called in response to
+// a chunk request, with the position and length given by the request. Sending
+// an empty chunk indicates that the transfer is complete.
+func (tox *Tox) FileSendChunk(friendNumber uint32, fileNumber uint32, position uint64, data []byte) (throw error) {
+    defer tox.wakeup()
+    tox.exec(&throw, func() {
+        var c_friend_number = C.uint32_t(friendNumber)
+        var c_file_number = C.uint32_t(fileNumber)
+        var c_position = C.uint64_t(position)
+        var c_length = C.size_t(len(data))
+        var c_data *C.uint8_t
+        var c_error C.TOX_ERR_FILE_SEND_CHUNK
+        if (c_length > 0) {
+            c_data = (*C.uint8_t)(&data[0])
+        }
+        C.tox_file_send_chunk(tox.handle, c_friend_number, c_file_number, c_position, c_data, c_length, &c_error)
+        if (c_error != C.TOX_ERR_FILE_SEND_CHUNK_OK) {
+            throw = errorFileSendChunk("tox_file_send_chunk", c_error)
+        }
+    })
     return
 }
 
@@ -810,27 +1103,120 @@ func (tox *Tox) Bootstrap(seedNode *SeedNode) (throw error) {
     var c_host = C.CString(seedNode.Host)
     defer C.free(unsafe.Pointer(c_host))
     var c_port = C.uint16_t(seedNode.Port)
-    publicKey, err := hex.DecodeString(seedNode.PublicKey)
-    if err != nil {
-        return err
+    publicKey, throw := seedNode.decodePublicKey()
+    if throw != nil {
+        return
     }
-    if (len(publicKey) != ToxPublicKeySize) {
-        return errors.New("invalid public key")
+    defer tox.wakeup()
+    tox.exec(&throw, func() {
+        var c_public_key = (*C.uint8_t)(&publicKey[0])
+        var c_error C.TOX_ERR_BOOTSTRAP
+        C.tox_bootstrap(tox.handle, c_host, c_port, c_public_key, &c_error)
+        if (c_error != C.TOX_ERR_BOOTSTRAP_OK) {
+            throw = errorBootstrap("tox_bootstrap", c_error)
+        }
+    })
+    return
+}
+
+// This function adds the given node as a TCP relay. Unlike Bootstrap, it does
+// not attempt to connect to the node over UDP, and the node is not used to
+// join the DHT. Clients that run with ToxOptions.UDPEnabled set to false, for
+// example behind a firewall that blocks UDP, connect to their friends through
+// TCP relays. The core forgets the relay when the instance is destroyed. See
+// TCPRelays for a list of relays that is added again on every reconnect.
+func (tox *Tox) AddTCPRelay(seedNode *SeedNode) (throw error) {
+    var c_host = C.CString(seedNode.Host)
+    defer C.free(unsafe.Pointer(c_host))
+    var c_port = C.uint16_t(seedNode.Port)
+    publicKey, throw := seedNode.decodePublicKey()
+    if throw != nil {
+        return
     }
-    var c_public_key = (*C.uint8_t)(&publicKey[0])
-    var c_error C.TOX_ERR_BOOTSTRAP
-    C.tox_bootstrap(tox.handle, c_host, c_port, c_public_key, &c_error)
-    if (c_error != C.TOX_ERR_BOOTSTRAP_OK) {
-        switch c_error {
-            case C.TOX_ERR_BOOTSTRAP_NULL:
-                throw = ToxErrBootstrapNull
-            case C.TOX_ERR_BOOTSTRAP_BAD_HOST:
-                throw = ToxErrBootstrapBadHost
-            case C.TOX_ERR_BOOTSTRAP_BAD_PORT:
-                throw = ToxErrBootstrapBadPort
-            default:
-                throw = ToxErrUnknown
+    defer tox.wakeup()
+    tox.exec(&throw, func() {
+        var c_public_key = (*C.uint8_t)(&publicKey[0])
+        var c_error C.TOX_ERR_BOOTSTRAP
+        C.tox_add_tcp_relay(tox.handle, c_host, c_port, c_public_key, &c_error)
+        if (c_error != C.TOX_ERR_BOOTSTRAP_OK) {
+            throw = errorBootstrap("tox_add_tcp_relay", c_error)
         }
+    })
+    return
+}
+
+// Get the temporary DHT public key of the Tox client. This is the public key
+// of the client as a node in the DHT, which other clients use to bootstrap off
+// it. It is not the long-term public key returned by GetPublicKey, and it
+// changes each time a Tox instance is created.
+func (tox *Tox) GetDHTId() (dhtId ToxPublicKey) {
+    tox.exec(nil, func() {
+        C.tox_self_get_dht_id(tox.handle, (*C.uint8_t)(&dhtId[0]))
+    })
+    return
+}
+
+// Get the UDP port the Tox client is bound to. The port is chosen by the core
+// from the range given by ToxOptions.StartPort and ToxOptions.EndPort.
+func (tox *Tox) GetUDPPort() (port uint16, throw error) {
+    tox.exec(&throw, func() {
+        var c_error C.TOX_ERR_GET_PORT
+        var c_port = C.tox_self_get_udp_port(tox.handle, &c_error)
+        if (c_error != C.TOX_ERR_GET_PORT_OK) {
+            throw = errorGetPort("tox_self_get_udp_port", c_error)
+            return
+        }
+        port = uint16(c_port)
+    })
+    return
+}
+
+// Get the port the TCP relay server of the Tox client is listening on. This
+// returns ToxErrGetPortNotBound unless ToxOptions.TCPPort was set.
+func (tox *Tox) GetTCPPort() (port uint16, throw error) {
+    tox.exec(&throw, func() {
+        var c_error C.TOX_ERR_GET_PORT
+        var c_port = C.tox_self_get_tcp_port(tox.handle, &c_error)
+        if (c_error != C.TOX_ERR_GET_PORT_OK) {
+            throw = errorGetPort("tox_self_get_tcp_port", c_error)
+            return
+        }
+        port = uint16(c_port)
+    })
+    return
+}
+
+// This function creates a seed node that other clients can bootstrap off to
+// reach the network through the Tox client. The host is the address at which
+// the client is reachable, since the core does not know it.
+func (tox *Tox) SeedNode(host string) (seedNode *SeedNode, throw error) {
+    port, throw := tox.GetUDPPort()
+    if throw != nil {
+        return
+    }
+    var dhtId = tox.GetDHTId()
+    return NewSeedNode(host, port, strings.ToUpper(hex.EncodeToString(dhtId[:]))), nil
+}
+
+// This function creates a seed node that other clients can add as a TCP relay
+// to reach the network through the TCP relay server of the Tox client.
+func (tox *Tox) TCPRelayNode(host string) (seedNode *SeedNode, throw error) {
+    port, throw := tox.GetTCPPort()
+    if throw != nil {
+        return
+    }
+    var dhtId = tox.GetDHTId()
+    return NewSeedNode(host, port, strings.ToUpper(hex.EncodeToString(dhtId[:]))), nil
+}
+
+// Decode the hexadecimal public key of a seed node.
+func (seedNode *SeedNode) decodePublicKey() (publicKey []byte, throw error) {
+    publicKey, throw = hex.DecodeString(seedNode.PublicKey)
+    if throw != nil {
+        return
+    }
+    if (len(publicKey) != ToxPublicKeySize) {
+        return nil, errors.New("invalid public key")
     }
     return
 }
@@ -13,10 +13,21 @@
 package tox
 
 import "bytes"
+import "context"
+import "errors"
 import "golang.org/x/crypto/curve25519"
+import "io"
+import "io/ioutil"
+import "log/slog"
 import "math/rand"
+import "os"
+import "path/filepath"
+import "runtime"
+import "strings"
+import "sync"
 import "testing"
 import "time"
+import "unicode/utf8"
 
 func RandomOptions(noise *rand.Rand) (options *ToxOptions, err error) {
     options = &ToxOptions{}
@@ -39,6 +50,7 @@ func RandomOptions(noise *rand.Rand) (options *ToxOptions, err error) {
     options.EndPort   = uint16(noise.Intn(65535) + 1)
     options.TCPPort   = uint16(noise.Intn(65535) + 1)
     client, err := New(nil)
+    options.SaveDataType = ToxSaveDataTypeToxSave
     options.SaveData = client.Serialize()
     return
 }
@@ -50,7 +62,7 @@ func TestConvertOptions(test *testing.T) {
         test.Fatal(err)
     }
     c_options, err := COptions(options)
-    defer c_options.FreeOptions()
+    defer freeOptions(c_options)
     result, err := GoOptions(c_options)
     if (options.IPv6Enabled != result.IPv6Enabled) {
         test.Fatalf("Failed to convert Tox startup options. IPv6 enabled option does not match.")
@@ -76,11 +88,47 @@ func TestConvertOptions(test *testing.T) {
     if (options.TCPPort != result.TCPPort) {
         test.Fatalf("Failed to convert Tox startup options. TCP port option does not match.")
     }
+    if (options.SaveDataType != result.SaveDataType) {
+        test.Fatalf("Failed to convert Tox startup options. Save data type option does not match.")
+    }
     if (!equal(options.SaveData, result.SaveData)) {
         test.Fatalf("Failed to convert Tox startup options. Save data option does not match.")
     }
 }
 
+func TestNewFromSecretKey(test *testing.T) {
+    tox, err := New(nil)
+    if err != nil {
+        test.Fatal(err)
+    }
+    defer tox.Destroy()
+    restored, err := NewFromSecretKey(tox.GetSecretKey(), nil)
+    if err != nil {
+        test.Fatal(err)
+    }
+    defer restored.Destroy()
+    if (restored.GetSecretKey() != tox.GetSecretKey()) {
+        test.Fatalf("Failed to create Tox instance from secret key. Secret key does not match.")
+    }
+    if (restored.GetPublicKey() != tox.GetPublicKey()) {
+        test.Fatalf("Failed to create Tox instance from secret key. Public key does not match.")
+    }
+    if (len(restored.GetFriendList()) != 0) {
+        test.Fatalf("Failed to create Tox instance from secret key. Friend list is not empty.")
+    }
+}
+
+func TestVersion(test *testing.T) {
+    version := Version()
+    header := HeaderVersion()
+    if (!VersionIsCompatible()) {
+        test.Fatalf("Tox core library %s is not compatible with header %s.", version, header)
+    }
+    if (version.Major != header.Major || version.Minor != header.Minor) {
+        test.Fatalf("Tox core library %s does not implement the API of header %s.", version, header)
+    }
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 ///////////////////////////////// MEMORY TESTS /////////////////////////////////
 ////////////////////////////////////////////////////////////////////////////////
@@ -166,10 +214,1278 @@ func TestPublicPrivateKeys(test *testing.T) {
     }
 }
 
+func TestEncryptSave(test *testing.T) {
+    tox := initialise(test)
+    defer tox.Destroy()
+    data := tox.Serialize()
+    passphrase := []byte("correct horse battery staple")
+    encrypted, err := EncryptSave(data, passphrase)
+    if err != nil {
+        test.Fatal(err)
+    }
+    if (!IsDataEncrypted(encrypted) || IsDataEncrypted(data)) {
+        test.Fatalf("Failed to detect encrypted save data.")
+    }
+    decrypted, err := DecryptSave(encrypted, passphrase)
+    if err != nil {
+        test.Fatal(err)
+    }
+    if (!equal(data, decrypted)) {
+        test.Fatalf("Failed to decrypt save data.")
+    }
+    _, err = DecryptSave(encrypted, []byte("wrong"))
+    if (!errors.Is(err, ToxErrDecryptionFailed)) {
+        test.Fatalf("Failed to reject wrong passphrase. Got %v.", err)
+    }
+    restored, err := New(&ToxOptions{SaveData: encrypted, Passphrase: passphrase})
+    if err != nil {
+        test.Fatal(err)
+    }
+    defer restored.Destroy()
+    if (restored.GetPublicKey() != tox.GetPublicKey()) {
+        test.Fatalf("Failed to restore Tox instance from encrypted save data.")
+    }
+}
+
+func TestPassKey(test *testing.T) {
+    passphrase := []byte("correct horse battery staple")
+    key, err := NewPassKey(passphrase)
+    if err != nil {
+        test.Fatal(err)
+    }
+    defer key.Destroy()
+    data := []byte("The quick brown fox jumps over the lazy dog.")
+    encrypted, err := key.Encrypt(data)
+    if err != nil {
+        test.Fatal(err)
+    }
+    salt, err := GetSalt(encrypted)
+    if err != nil {
+        test.Fatal(err)
+    }
+    if (salt != key.Salt()) {
+        test.Fatalf("Failed to extract salt from encrypted data.")
+    }
+    derived, err := NewPassKeyWithSalt(passphrase, salt)
+    if err != nil {
+        test.Fatal(err)
+    }
+    decrypted, err := derived.Decrypt(encrypted)
+    if err != nil {
+        test.Fatal(err)
+    }
+    if (!equal(data, decrypted)) {
+        test.Fatalf("Failed to decrypt data with derived pass key.")
+    }
+    decrypted, err = DecryptSave(encrypted, passphrase)
+    if (err != nil || !equal(data, decrypted)) {
+        test.Fatalf("Failed to decrypt pass key data with passphrase.")
+    }
+    derived.Destroy()
+    derived.Destroy()
+    _, err = derived.Encrypt(data)
+    if (err != ToxErrClosed) {
+        test.Fatalf("Failed to reject use of destroyed pass key.")
+    }
+}
+
+////////////////////////////////////////////////////////////////////////////////
+////////////////////////////// CONCURRENCY TESTS ///////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+func TestConcurrentAccess(test *testing.T) {
+    tox := initialise(test)
+    defer tox.Destroy()
+    hammer(tox)
+}
+
+func TestDedicatedThread(test *testing.T) {
+    tox, err := New(&ToxOptions{IPv6Enabled: true, UDPEnabled: true, DedicatedThread: true})
+    if err != nil {
+        test.Fatal(err)
+    }
+    defer tox.Destroy()
+    hammer(tox)
+    input := []byte("owner")
+    err = tox.SetName(input)
+    if err != nil {
+        test.Fatal(err)
+    }
+    if (!equal(input, tox.GetName())) {
+        test.Fatalf("Failed concurrency test. Calls were not executed in order.")
+    }
+}
+
+func TestDedicatedThreadHelpers(test *testing.T) {
+    tox, err := New(&ToxOptions{IPv6Enabled: true, UDPEnabled: true, DedicatedThread: true})
+    if err != nil {
+        test.Fatal(err)
+    }
+    friend := initialise(test)
+    defer friend.Destroy()
+    friendNumber, err := tox.FriendAddNoRequest(friend.GetPublicKey())
+    if err != nil {
+        test.Fatal(err)
+    }
+    outbox, err := NewOutbox(tox, &memoryOutboxStore{})
+    if err != nil {
+        test.Fatal(err)
+    }
+    tracker := tox.Deliveries()
+    indicator := tox.NewTypingIndicator(friendNumber, time.Hour)
+    stop := make(chan struct{})
+    handled := make(chan struct{})
+    go func() {
+        defer close(handled)
+        for {
+            select {
+                case <-stop:
+                    return
+                default:
+            }
+            tox.exec(nil, func() {
+                tox.enqueue(func() {
+                    tox.handleDeliveryReadReceipt(friendNumber, 0)
+                    tox.handleDeliveryConnectionStatus(friendNumber, ToxConnectionNone)
+                    tox.handleOutboxReadReceipt(friendNumber, 0)
+                    tox.handleOutboxConnectionStatus(friendNumber, ToxConnectionNone)
+                    tox.handleFileChunkRequest(friendNumber, 0, 0, 1)
+                    tox.handleTransfersConnectionStatus(friendNumber, ToxConnectionNone)
+                    indicator.Clear()
+                })
+            })
+            tox.Wake()
+        }
+    }()
+    var group sync.WaitGroup
+    helpers := []func() {
+        func() { tracker.Send(friendNumber, ToxMessageTypeNormal, []byte("tracked")) },
+        func() { outbox.Send(friendNumber, ToxMessageTypeNormal, []byte("queued")) },
+        func() { tox.SendFile(friendNumber, []byte("file"), bytes.NewReader([]byte("data")), 4) },
+        func() { indicator.Touch() },
+    }
+    for _, helper := range helpers {
+        group.Add(1)
+        go func(helper func()) {
+            defer group.Done()
+            for i := 0; i < 200; i++ {
+                helper()
+            }
+        }(helper)
+    }
+    finished := make(chan struct{})
+    go func() {
+        group.Wait()
+        close(stop)
+        <-handled
+        close(finished)
+    }()
+    select {
+        case <-finished:
+        case <-time.After(10 * time.Second):
+            test.Fatalf("Failed concurrency test. Helpers deadlocked with the handlers on the owner thread.")
+    }
+    tox.Destroy()
+}
+
+func TestDedicatedThreadOrdering(test *testing.T) {
+    tox, err := New(&ToxOptions{IPv6Enabled: true, UDPEnabled: true, DedicatedThread: true})
+    if err != nil {
+        test.Fatal(err)
+    }
+    defer tox.Destroy()
+    var lock sync.Mutex
+    var order []string
+    record := func(step string) {
+        lock.Lock()
+        order = append(order, step)
+        lock.Unlock()
+    }
+    started := make(chan struct{})
+    tox.exec(nil, func() {
+        tox.enqueue(func() {
+            close(started)
+            time.Sleep(20 * time.Millisecond)
+            tox.SetName([]byte("callback"))
+            record("callback")
+        })
+    })
+    tox.Wake()
+    select {
+        case <-started:
+        case <-time.After(5 * time.Second):
+            test.Fatalf("Failed concurrency test. Callback did not run.")
+    }
+    name := tox.GetName()
+    record("call")
+    if (!equal(name, []byte("callback"))) {
+        test.Fatalf("Failed concurrency test. Call ran before the callback finished.")
+    }
+    lock.Lock()
+    defer lock.Unlock()
+    if (len(order) != 2 || order[0] != "callback" || order[1] != "call") {
+        test.Fatalf("Failed concurrency test. Callback and call ran in the order %v.", order)
+    }
+}
+
+func hammer(tox *Tox) {
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    go tox.Run(ctx)
+    var group sync.WaitGroup
+    for i := 0; i < 16; i++ {
+        group.Add(1)
+        go func(seed int64) {
+            defer group.Done()
+            noise := rand.New(rand.NewSource(seed))
+            for j := 0; j < 100; j++ {
+                name := make([]byte, noise.Intn(ToxMaxNameLength+1))
+                noise.Read(name)
+                tox.SetName(name)
+                tox.GetName()
+                tox.SetStatusMessage(name)
+                tox.GetStatusMessage()
+                tox.Serialize()
+                tox.SetOnFriendMessage(func(tox *Tox, friendNumber uint32, messageType ToxMessageType, message []byte) {
+                    tox.GetName()
+                })
+                unsubscribe := tox.OnFriendName(func(tox *Tox, friendNumber uint32, name []byte) {
+                    tox.GetFriendList()
+                })
+                var address ToxAddress
+                noise.Read(address[:])
+                if friendNumber, err := tox.FriendAdd(address, []byte("hello")); err == nil {
+                    tox.FriendSendMessage(friendNumber, ToxMessageTypeNormal, name)
+                    tox.FriendDelete(friendNumber)
+                }
+                tox.GetFriendList()
+                tox.Process()
+                unsubscribe()
+            }
+        }(time.Now().UnixNano() + int64(i))
+    }
+    group.Wait()
+}
+
+func TestConcurrentSubscriptions(test *testing.T) {
+    var list subscribers
+    var group sync.WaitGroup
+    for i := 0; i < 16; i++ {
+        group.Add(1)
+        go func() {
+            defer group.Done()
+            for j := 0; j < 1000; j++ {
+                unsubscribe := list.add(OnFriendTyping(func(*Tox, uint32, bool) {}))
+                for _, subscriber := range list.snapshot() {
+                    subscriber.callback.(OnFriendTyping)(nil, 0, false)
+                }
+                unsubscribe()
+            }
+        }()
+    }
+    group.Wait()
+    if (len(list.snapshot()) != 0) {
+        test.Fatalf("Failed concurrency test. Subscribers remain after unsubscribing.")
+    }
+}
+
+////////////////////////////////////////////////////////////////////////////////
+/////////////////////////////// LIFECYCLE TESTS ////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+func TestDestroyTwice(test *testing.T) {
+    tox := initialise(test)
+    events, _ := tox.Events(1, OverflowDropNewest)
+    tox.Destroy()
+    tox.Destroy()
+    if err := tox.SetName([]byte("closed")); err != ToxErrClosed {
+        test.Fatalf("Failed lifecycle test. Expected %v, got %v.", ToxErrClosed, err)
+    }
+    if name := tox.GetName(); name != nil {
+        test.Fatalf("Failed lifecycle test. Getter returned data after destroy.")
+    }
+    tox.Process()
+    if _, open := <-events; open {
+        test.Fatalf("Failed lifecycle test. Event stream is still open.")
+    }
+}
+
+func TestClosedExec(test *testing.T) {
+    tox := &Tox{newToxState()}
+    close(tox.run.done)
+    var err error
+    tox.exec(&err, func() {
+        test.Fatalf("Failed lifecycle test. Function ran after destroy.")
+    })
+    if (err != ToxErrClosed) {
+        test.Fatalf("Failed lifecycle test. Expected %v, got %v.", ToxErrClosed, err)
+    }
+}
+
+func TestFinalizer(test *testing.T) {
+    awaitFinalizer(test, initialise(test).id)
+    tox, err := New(&ToxOptions{IPv6Enabled: true, UDPEnabled: true, DedicatedThread: true})
+    if err != nil {
+        test.Fatal(err)
+    }
+    awaitFinalizer(test, tox.id)
+    tox = initialise(test)
+    tox.Deliveries()
+    tox.NewTypingIndicator(0, time.Second)
+    if _, err := NewTCPRelays(tox); err != nil {
+        test.Fatal(err)
+    }
+    id := tox.id
+    tox = nil
+    awaitFinalizer(test, id)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+/////////////////////////////// VALIDATION TESTS ///////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+func TestToxError(test *testing.T) {
+    var err error = newToxError("tox_friend_add", 6, ToxErrFriendAddBadChecksum)
+    if (!errors.Is(err, ToxErrFriendAddBadChecksum) || errors.Is(err, ToxErrFriendAddOwnKey)) {
+        test.Fatalf("Failed error test. Error does not match its sentinel.")
+    }
+    var toxError *ToxError
+    if (!errors.As(err, &toxError) || toxError.Op != "tox_friend_add" || toxError.Code != 6) {
+        test.Fatalf("Failed error test. Operation or code was not preserved.")
+    }
+    if (toxError.Category != ToxErrorCategoryArgument) {
+        test.Fatalf("Failed error test. Expected category %v, got %v.", ToxErrorCategoryArgument, toxError.Category)
+    }
+    err = newToxError("tox_friend_add", 42, ToxErrUnknown)
+    if (!errors.Is(err, ToxErrUnknown) || !errors.As(err, &toxError) || toxError.Code != 42) {
+        test.Fatalf("Failed error test. Unknown code was not preserved.")
+    }
+    if (toxError.Category != ToxErrorCategoryUnknown) {
+        test.Fatalf("Failed error test. Expected category %v, got %v.", ToxErrorCategoryUnknown, toxError.Category)
+    }
+}
+
+func TestLossyPacketRange(test *testing.T) {
+    tox := &Tox{newToxState()}
+    for _, first := range []byte{0, 160, 191, 199, 255} {
+        err := tox.FriendSendLossyPacket(0, []byte{first, 0})
+        if err != ToxErrFriendCustomPacketInvalid {
+            test.Fatalf("Failed validation test for lossy packet. First byte %d was accepted.", first)
+        }
+    }
+    err := tox.FriendSendLossyPacket(0, nil)
+    if err != ToxErrFriendCustomPacketEmpty {
+        test.Fatalf("Failed validation test for lossy packet. Empty packet was accepted.")
+    }
+}
+
+func TestSplitMessage(test *testing.T) {
+    noise := rand.New(rand.NewSource(time.Now().UnixNano()))
+    words := []string{"tox", "\u00e9t\u00e9", "\u4f60\u597d", "\U0001f600\U0001f600", "\n", "supercalifragilistic"}
+    var buffer bytes.Buffer
+    for buffer.Len() < 4 * ToxMaxMessageLength {
+        buffer.WriteString(words[noise.Intn(len(words))])
+        if (noise.Intn(4) > 0) {
+            buffer.WriteByte(' ')
+        }
+    }
+    input := buffer.Bytes()
+    marker := []byte("\u2026 ")
+    parts := SplitMessage(input, ToxMaxMessageLength, marker)
+    var output []byte
+    for i, part := range parts {
+        if (len(part) > ToxMaxMessageLength || !utf8.Valid(part)) {
+            test.Fatalf("Failed split test. Part %d is too long or not valid UTF-8.", i)
+        }
+        if (i > 0) {
+            if (!bytes.HasPrefix(part, marker)) {
+                test.Fatalf("Failed split test. Part %d lacks the continuation marker.", i)
+            }
+            part = part[len(marker):]
+        }
+        output = append(output, part...)
+    }
+    if (!equal(input, output)) {
+        test.Fatalf("Failed split test. Parts do not reassemble into the message.")
+    }
+}
+
+func TestRunStats(test *testing.T) {
+    tox := &Tox{newToxState()}
+    tox.Wake()
+    tox.Wake()
+    if (len(tox.run.wake) != 1) {
+        test.Fatalf("Failed run test. Wakeups were not coalesced.")
+    }
+    tox.run.record(50 * time.Millisecond, 20 * time.Millisecond)
+    tox.run.record(50 * time.Millisecond, 80 * time.Millisecond)
+    tox.run.record(50 * time.Millisecond, 60 * time.Millisecond)
+    stats := tox.RunStats()
+    if (stats.Iterations != 3 || stats.Overruns != 2) {
+        test.Fatalf("Failed run test. Counted %d iterations and %d overruns.", stats.Iterations, stats.Overruns)
+    }
+    if (stats.TotalOverrun != 40 * time.Millisecond || stats.MaxOverrun != 30 * time.Millisecond) {
+        test.Fatalf("Failed run test. Overrun durations are incorrect.")
+    }
+    if (stats.LastDuration != 60 * time.Millisecond) {
+        test.Fatalf("Failed run test. Last duration is incorrect.")
+    }
+}
+
+func TestEventOverflow(test *testing.T) {
+    tox := &Tox{newToxState()}
+    oldest := newEventStream(2, OverflowDropOldest)
+    newest := newEventStream(2, OverflowDropNewest)
+    tox.streams = []*eventStream{oldest, newest}
+    for i := uint32(0); i < 4; i++ {
+        tox.publish(FriendStatusEvent{FriendNumber: i})
+    }
+    tox.closeStreams()
+    check := func(stream *eventStream, expected ...uint32) {
+        var received []uint32
+        for event := range stream.events {
+            received = append(received, event.(FriendStatusEvent).FriendNumber)
+        }
+        if (len(received) != len(expected)) {
+            test.Fatalf("Failed event test. Received %v instead of %v.", received, expected)
+        }
+        for i := range expected {
+            if (received[i] != expected[i]) {
+                test.Fatalf("Failed event test. Received %v instead of %v.", received, expected)
+            }
+        }
+    }
+    check(oldest, 2, 3)
+    check(newest, 0, 1)
+}
+
+func TestEventCancel(test *testing.T) {
+    tox := &Tox{newToxState()}
+    kept := newEventStream(1, OverflowDropNewest)
+    tox.streams = []*eventStream{kept}
+    stalled := newEventStream(0, OverflowBlock)
+    tox.streams = append(tox.streams, stalled)
+    published := make(chan struct{})
+    go func() {
+        tox.publish(FriendStatusEvent{FriendNumber: 1})
+        close(published)
+    }()
+    select {
+        case <-published:
+            test.Fatalf("Failed event test. Event was published to a stalled stream.")
+        case <-time.After(10 * time.Millisecond):
+    }
+    tox.removeStream(stalled)
+    tox.removeStream(stalled)
+    select {
+        case <-published:
+        case <-time.After(5 * time.Second):
+            test.Fatalf("Failed event test. Cancelled stream still blocks the event loop.")
+    }
+    if _, open := <-stalled.events; open {
+        test.Fatalf("Failed event test. Cancelled stream is still open.")
+    }
+    if (len(tox.streams) != 1 || tox.streams[0] != kept) {
+        test.Fatalf("Failed event test. Cancelled stream was not removed.")
+    }
+    tox.publish(FriendStatusEvent{FriendNumber: 2})
+    if event := <-kept.events; (event.(FriendStatusEvent).FriendNumber != 1) {
+        test.Fatalf("Failed event test. Remaining stream missed an event.")
+    }
+}
+
+func TestSubscribers(test *testing.T) {
+    var list subscribers
+    var calls []int
+    var cancels []func()
+    for i := 0; i < 4; i++ {
+        n := i
+        cancels = append(cancels, list.add(OnFriendTyping(func(*Tox, uint32, bool) {
+            calls = append(calls, n)
+            if (n == 0) {
+                cancels[2]()
+            }
+        })))
+    }
+    dispatch := func() {
+        for _, subscriber := range list.snapshot() {
+            subscriber.callback.(OnFriendTyping)(nil, 0, true)
+        }
+    }
+    dispatch()
+    cancels[2]()
+    cancels[0]()
+    dispatch()
+    expected := []int{0, 1, 2, 3, 1, 3}
+    if (len(calls) != len(expected)) {
+        test.Fatalf("Failed subscriber test. Called %v instead of %v.", calls, expected)
+    }
+    for i := range expected {
+        if (calls[i] != expected[i]) {
+            test.Fatalf("Failed subscriber test. Called %v instead of %v.", calls, expected)
+        }
+    }
+}
+
+////////////////////////////////////////////////////////////////////////////////
+//////////////////////////////// TRANSFER TESTS ////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+func TestOutgoingFileTransfer(test *testing.T) {
+    tox := &Tox{newToxState()}
+    tox.transfers = make(map[transferKey]*FileTransfer)
+    data := []byte("0123456789")
+    transfer := newFileTransfer(tox, 0, 1, uint64(len(data)))
+    transfer.reader = bytes.NewReader(data)
+    tox.transfers[transferKey{0, 1}] = transfer
+    if transferred, size := transfer.Progress(); (transferred != 0 || size != 10) {
+        test.Fatalf("Failed transfer test. Progress is %d of %d before the first chunk.", transferred, size)
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+    defer cancel()
+    if err := transfer.Wait(ctx); err != context.DeadlineExceeded {
+        test.Fatalf("Failed transfer test. Wait returned %v before completion.", err)
+    }
+    if (tox.handleFileChunkRequest(0, 2, 0, 4)) {
+        test.Fatalf("Failed transfer test. Chunk request for an unmanaged transfer was handled.")
+    }
+    if (!tox.handleFileChunkRequest(0, 1, 0, 0)) {
+        test.Fatalf("Failed transfer test. Final chunk request was not handled.")
+    }
+    if transferred, size := transfer.Progress(); (transferred != size) {
+        test.Fatalf("Failed transfer test. Progress is %d of %d after completion.", transferred, size)
+    }
+    if err := transfer.Wait(context.Background()); err != nil {
+        test.Fatalf("Failed transfer test. Completed transfer returned %v.", err)
+    }
+    if (tox.lookupTransfer(0, 1) != nil) {
+        test.Fatalf("Failed transfer test. Completed transfer is still tracked.")
+    }
+    close(tox.run.done)
+    short := newFileTransfer(tox, 0, 3, uint64(len(data)))
+    short.reader = bytes.NewReader(data[:4])
+    tox.transfers[transferKey{0, 3}] = short
+    tox.handleFileChunkRequest(0, 3, 0, len(data))
+    if err := short.Wait(context.Background()); err != io.EOF {
+        test.Fatalf("Failed transfer test. Short read returned %v.", err)
+    }
+    if (tox.lookupTransfer(0, 3) != nil) {
+        test.Fatalf("Failed transfer test. Cancelled transfer is still tracked.")
+    }
+}
+
+func TestHeldTransferEvents(test *testing.T) {
+    tox := &Tox{newToxState()}
+    tox.transfers = make(map[transferKey]*FileTransfer)
+    tox.offering = make(map[uint32]int)
+    tox.held = make(map[transferKey]*heldTransferEvents)
+    if (tox.holdTransferEvent(0, 4, func() {})) {
+        test.Fatalf("Failed transfer test. Event was held without a pending offer.")
+    }
+    tox.offering[0] = 1
+    var order []int
+    for i := 0; i < 2; i++ {
+        i := i
+        held := tox.holdTransferEvent(0, 4, func() {
+            order = append(order, i)
+            if (i == 0 && !tox.holdTransferEvent(0, 4, func() { order = append(order, 2) })) {
+                test.Fatalf("Failed transfer test. Event during release was not held.")
+            }
+        })
+        if (!held) {
+            test.Fatalf("Failed transfer test. Event during offer was not held.")
+        }
+    }
+    delete(tox.offering, 0)
+    tox.transfers[transferKey{0, 4}] = newFileTransfer(tox, 0, 4, 10)
+    tox.releaseTransferEvents(transferKey{0, 4})
+    if (len(order) != 3 || order[0] != 0 || order[1] != 1 || order[2] != 2) {
+        test.Fatalf("Failed transfer test. Held events ran in order %v.", order)
+    }
+    if (len(tox.held) != 0 || tox.holdTransferEvent(0, 4, func() {})) {
+        test.Fatalf("Failed transfer test. Events were held after release.")
+    }
+}
+
+func TestIncomingFileTransfer(test *testing.T) {
+    tox := &Tox{newToxState()}
+    tox.transfers = make(map[transferKey]*FileTransfer)
+    var offered []*IncomingFileTransfer
+    tox.onIncomingFile = func(tox *Tox, transfer *IncomingFileTransfer) {
+        offered = append(offered, transfer)
+    }
+    for fileNumber := uint32(0); fileNumber < 4; fileNumber++ {
+        if (!tox.handleFileRecv(0, fileNumber, ToxFileKindData, 8, []byte("file"))) {
+            test.Fatalf("Failed transfer test. File offer was not handled.")
+        }
+    }
+    if (len(offered) != 4 || !equal(offered[0].Filename, []byte("file"))) {
+        test.Fatalf("Failed transfer test. File offers were not passed to the callback.")
+    }
+    tox.handleFileRecvChunk(0, 0, 0, []byte("lost"))
+    if transferred, _ := offered[0].Progress(); (transferred != 0) {
+        test.Fatalf("Failed transfer test. Chunk was written before the transfer was accepted.")
+    }
+    file := &memoryFile{}
+    offered[0].writer = file
+    tox.handleFileRecvChunk(0, 0, 4, []byte("4567"))
+    tox.handleFileRecvChunk(0, 0, 0, []byte("0123"))
+    if transferred, size := offered[0].Progress(); (transferred != 4 || size != 8) {
+        test.Fatalf("Failed transfer test. Progress is %d of %d.", transferred, size)
+    }
+    tox.handleFileRecvChunk(0, 0, 8, nil)
+    if err := offered[0].Wait(context.Background()); err != nil {
+        test.Fatalf("Failed transfer test. Completed transfer returned %v.", err)
+    }
+    if (!equal(file.data, []byte("01234567"))) {
+        test.Fatalf("Failed transfer test. Received %q.", file.data)
+    }
+    offered[1].writer = file
+    tox.handleTransfersConnectionStatus(0, ToxConnectionNone)
+    if err := offered[1].Wait(context.Background()); err != ToxErrFileTransferBroken {
+        test.Fatalf("Failed transfer test. Broken transfer returned %v.", err)
+    }
+    tox = &Tox{newToxState()}
+    tox.transfers = make(map[transferKey]*FileTransfer)
+    close(tox.run.done)
+    rejected := &IncomingFileTransfer{FileTransfer: newFileTransfer(tox, 0, 2, 8)}
+    rejected.Reject()
+    if err := rejected.Wait(context.Background()); err != ToxErrFileTransferRejected {
+        test.Fatalf("Failed transfer test. Rejected transfer returned %v.", err)
+    }
+    if err := rejected.Accept(file); err != ToxErrFileTransferNotIncoming {
+        test.Fatalf("Failed transfer test. Rejected transfer was accepted.")
+    }
+    failed := &IncomingFileTransfer{FileTransfer: newFileTransfer(tox, 0, 3, 8)}
+    if err := failed.Accept(file); err != ToxErrClosed {
+        test.Fatalf("Failed transfer test. Accept on a destroyed instance returned %v.", err)
+    }
+    if err := failed.Wait(context.Background()); err != ToxErrClosed {
+        test.Fatalf("Failed transfer test. Failed accept left the transfer open.")
+    }
+}
+
+func TestResumableAttempt(test *testing.T) {
+    tox := &Tox{newToxState()}
+    manager := &ResumableTransfers {
+        tox: tox.view,
+        active: make(map[ToxFileId]*FileTransfer),
+    }
+    fileId := ToxFileId{1}
+    transfer := newFileTransfer(tox, 0, 1, 4)
+    manager.active[fileId] = transfer
+    if (manager.Transfer(fileId) != transfer) {
+        test.Fatalf("Failed resume test. Attempt in progress was not returned.")
+    }
+    transfer.finish(ToxErrFileTransferBroken)
+    manager.lock.Lock()
+    var attempting = manager.attempting(fileId)
+    manager.lock.Unlock()
+    if (attempting || manager.Transfer(fileId) != nil) {
+        test.Fatalf("Failed resume test. Broken attempt still counts as in progress.")
+    }
+}
+
+////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////// TYPING TESTS /////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+func TestTypingIndicator(test *testing.T) {
+    var lock sync.Mutex
+    var sent []bool
+    var fail error
+    indicator := &TypingIndicator {
+        setTyping: func(friendNumber uint32, isTyping bool) error {
+            lock.Lock()
+            defer lock.Unlock()
+            if fail != nil {
+                return fail
+            }
+            sent = append(sent, isTyping)
+            return nil
+        },
+        idle: time.Hour,
+    }
+    failWith := func(err error) {
+        lock.Lock()
+        fail = err
+        lock.Unlock()
+    }
+    sentCount := func() int {
+        lock.Lock()
+        defer lock.Unlock()
+        return len(sent)
+    }
+    typing := func() bool {
+        indicator.lock.Lock()
+        defer indicator.lock.Unlock()
+        return indicator.isTyping
+    }
+    indicator.Touch()
+    indicator.Touch()
+    if (sentCount() != 1 || !typing()) {
+        test.Fatalf("Failed typing test. Repeated touches sent %d updates.", sentCount())
+    }
+    failWith(ToxErrSetTypingFriendNotFound)
+    if err := indicator.Clear(); !errors.Is(err, ToxErrSetTypingFriendNotFound) {
+        test.Fatalf("Failed typing test. Failed clear returned %v.", err)
+    }
+    if (!typing()) {
+        test.Fatalf("Failed typing test. Failed clear reset the typing status.")
+    }
+    failWith(nil)
+    if err := indicator.Clear(); (err != nil || typing() || sentCount() != 2) {
+        test.Fatalf("Failed typing test. Clear did not send the cleared status.")
+    }
+    indicator.Clear()
+    if (sentCount() != 2) {
+        test.Fatalf("Failed typing test. Clearing twice sent the cleared status twice.")
+    }
+    indicator.Touch()
+    failWith(ToxErrSetTypingFriendNotFound)
+    indicator.lock.Lock()
+    indicator.idle = time.Millisecond
+    var generation = indicator.generation
+    indicator.lock.Unlock()
+    indicator.expire(generation)
+    if (!typing()) {
+        test.Fatalf("Failed typing test. Failed expiry reset the typing status.")
+    }
+    failWith(nil)
+    var deadline = time.Now().Add(time.Second)
+    for (typing() && time.Now().Before(deadline)) {
+        time.Sleep(time.Millisecond)
+    }
+    if (typing() || sentCount() != 4) {
+        test.Fatalf("Failed typing test. Failed expiry was not tried again.")
+    }
+    indicator.lock.Lock()
+    indicator.idle = time.Hour
+    indicator.lock.Unlock()
+    indicator.Touch()
+    failWith(ToxErrClosed)
+    indicator.lock.Lock()
+    generation = indicator.generation
+    indicator.lock.Unlock()
+    indicator.expire(generation)
+    indicator.lock.Lock()
+    var timer = indicator.timer
+    indicator.lock.Unlock()
+    if (timer != nil) {
+        test.Fatalf("Failed typing test. Expiry on a destroyed instance was tried again.")
+    }
+    sent = nil
+    cleared := false
+    indicator = &TypingIndicator {
+        idle: time.Hour,
+    }
+    indicator.setTyping = func(friendNumber uint32, isTyping bool) error {
+        sent = append(sent, isTyping)
+        if (!cleared) {
+            cleared = true
+            if err := indicator.Clear(); err != nil {
+                test.Fatalf("Failed typing test. Clear during touch returned %v.", err)
+            }
+        }
+        return nil
+    }
+    if err := indicator.Touch(); err != nil {
+        test.Fatal(err)
+    }
+    if (len(sent) != 2 || !sent[0] || sent[1] || typing()) {
+        test.Fatalf("Failed typing test. Clear during touch sent %v.", sent)
+    }
+}
+
+////////////////////////////////////////////////////////////////////////////////
+//////////////////////////////// DELIVERY TESTS ////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+func TestDeliveryTracker(test *testing.T) {
+    tox := &Tox{newToxState()}
+    tox.deliveries = newDeliveryTracker(nil)
+    tracker := tox.deliveries
+    for _, key := range []deliveryKey{{0, 1}, {0, 2}, {1, 1}} {
+        tracker.messages[key] = &delivery{DeliveryPending, make(chan struct{})}
+    }
+    tox.handleDeliveryReadReceipt(0, 1)
+    tox.handleDeliveryConnectionStatus(0, ToxConnectionNone)
+    state, err := tracker.WaitTimeout(0, 1, time.Second)
+    if (err != nil || state != DeliveryDelivered) {
+        test.Fatalf("Failed delivery test. Receipted message was not delivered.")
+    }
+    state, err = tracker.WaitTimeout(0, 2, time.Second)
+    if (err != nil || state != DeliveryFailed) {
+        test.Fatalf("Failed delivery test. Message to disconnected friend did not fail.")
+    }
+    state, err = tracker.WaitTimeout(1, 1, time.Millisecond)
+    if (err == nil || state != DeliveryPending) {
+        test.Fatalf("Failed delivery test. Message without receipt did not time out.")
+    }
+    tracker.send = func(friendNumber uint32, messageType ToxMessageType, message []byte) (uint32, error) {
+        tox.handleDeliveryReadReceipt(friendNumber, 7)
+        return 7, nil
+    }
+    if _, err = tracker.Send(2, ToxMessageTypeNormal, []byte("early")); err != nil {
+        test.Fatal(err)
+    }
+    if state, _ = tracker.State(2, 7); (state != DeliveryDelivered) {
+        test.Fatalf("Failed delivery test. Receipt during send was lost.")
+    }
+    tracker.send = func(friendNumber uint32, messageType ToxMessageType, message []byte) (uint32, error) {
+        tox.handleDeliveryConnectionStatus(friendNumber, ToxConnectionNone)
+        return 8, nil
+    }
+    if _, err = tracker.Send(2, ToxMessageTypeNormal, []byte("lost")); err != nil {
+        test.Fatal(err)
+    }
+    if state, _ = tracker.State(2, 8); (state != DeliveryFailed) {
+        test.Fatalf("Failed delivery test. Connection loss during send was lost.")
+    }
+    if (len(tracker.sending) != 0 || len(tracker.receipts) != 0) {
+        test.Fatalf("Failed delivery test. Send state was not released.")
+    }
+}
+
+func TestSendMessageContext(test *testing.T) {
+    notConnected := newToxError("tox_friend_send_message", 2, ToxErrFriendSendMessageFriendNotConnected)
+    sendQ := newToxError("tox_friend_send_message", 3, ToxErrFriendSendMessageSendQ)
+    type result struct {
+        messageId uint32
+        err       error
+    }
+    start := func(ctx context.Context, results ...error) (*Tox, chan uint32, chan result) {
+        tox := &Tox{newToxState()}
+        attempts := make(chan uint32, 16)
+        var attempt uint32
+        tox.deliveries = newDeliveryTracker(func(friendNumber uint32, messageType ToxMessageType, message []byte) (uint32, error) {
+            var err = results[len(results) - 1]
+            if (int(attempt) < len(results)) {
+                err = results[attempt]
+            }
+            attempt++
+            attempts <- attempt
+            return attempt, err
+        })
+        tox.deliveriesOnce.Do(func() {})
+        done := make(chan result, 1)
+        go func() {
+            messageId, err := tox.SendMessageContext(ctx, 0, ToxMessageTypeNormal, []byte("hello"))
+            done <- result{messageId, err}
+        }()
+        return tox, attempts, done
+    }
+    finish := func(done chan result) result {
+        select {
+            case outcome := <-done:
+                return outcome
+            case <-time.After(5 * time.Second):
+                test.Fatalf("Failed send test. SendMessageContext did not return.")
+        }
+        return result{}
+    }
+    tox, attempts, done := start(context.Background(), notConnected, nil)
+    <-attempts
+    tox.handleDeliveryConnectionStatus(0, ToxConnectionUDP)
+    tox.handleDeliveryReadReceipt(0, <-attempts)
+    if outcome := finish(done); (outcome.err != nil || outcome.messageId != 2) {
+        test.Fatalf("Failed send test. Message to offline friend returned %d, %v.", outcome.messageId, outcome.err)
+    }
+    tox, attempts, done = start(context.Background(), sendQ, nil)
+    <-attempts
+    tox.handleDeliveryReadReceipt(0, <-attempts)
+    if outcome := finish(done); (outcome.err != nil || outcome.messageId != 2) {
+        test.Fatalf("Failed send test. Message with full send queue returned %d, %v.", outcome.messageId, outcome.err)
+    }
+    tox, attempts, done = start(context.Background(), nil)
+    <-attempts
+    tox.handleDeliveryConnectionStatus(0, ToxConnectionNone)
+    tox.handleDeliveryReadReceipt(0, <-attempts)
+    if outcome := finish(done); (outcome.err != nil || outcome.messageId != 2) {
+        test.Fatalf("Failed send test. Failed message returned %d, %v.", outcome.messageId, outcome.err)
+    }
+    ctx, cancel := context.WithCancel(context.Background())
+    tox, attempts, done = start(ctx, notConnected)
+    <-attempts
+    cancel()
+    if outcome := finish(done); (outcome.err != context.Canceled) {
+        test.Fatalf("Failed send test. Cancelled wait for friend returned %v.", outcome.err)
+    }
+    ctx, cancel = context.WithCancel(context.Background())
+    tox, attempts, done = start(ctx, nil)
+    messageId := <-attempts
+    cancel()
+    if outcome := finish(done); (outcome.err != context.Canceled) {
+        test.Fatalf("Failed send test. Cancelled wait for receipt returned %v.", outcome.err)
+    }
+    if _, ok := tox.deliveries.State(0, messageId); ok {
+        test.Fatalf("Failed send test. Cancelled message is still tracked.")
+    }
+}
+
+func TestOutbox(test *testing.T) {
+    sendQ := newToxError("tox_friend_send_message", 3, ToxErrFriendSendMessageSendQ)
+    store := &memoryOutboxStore{throw: errors.New("disk full")}
+    var lock sync.Mutex
+    var full = true
+    var messageId uint32
+    sent := make(chan uint32, 8)
+    outbox := &Outbox {
+        send: func(friendNumber uint32, messageType ToxMessageType, message []byte) (uint32, error) {
+            lock.Lock()
+            defer lock.Unlock()
+            if full {
+                full = false
+                return 0, sendQ
+            }
+            messageId++
+            sent <- messageId
+            return messageId, nil
+        },
+        publicKey: func(friendNumber uint32) (ToxPublicKey, error) {
+            return ToxPublicKey{1}, nil
+        },
+        store: store,
+        inflight: make(map[deliveryKey]*outboxEntry),
+        retrying: make(map[uint32]bool),
+        flushing: make(map[uint32]bool),
+        receipts: make(map[deliveryKey]bool),
+    }
+    failures := make(chan error, 1)
+    outbox.SetOnError(func(outbox *Outbox, err error) {
+        failures <- err
+    })
+    tox := &Tox{newToxState()}
+    tox.outbox = outbox
+    if err := outbox.Send(0, ToxMessageTypeNormal, []byte("hello")); err != nil {
+        test.Fatal(err)
+    }
+    select {
+        case id := <-sent:
+            tox.handleOutboxReadReceipt(0, id)
+        case <-time.After(5 * time.Second):
+            test.Fatalf("Failed outbox test. Message was not sent again after the send queue was full.")
+    }
+    select {
+        case err := <-failures:
+            if (err != store.throw) {
+                test.Fatalf("Failed outbox test. Error callback received %v.", err)
+            }
+        case <-time.After(5 * time.Second):
+            test.Fatalf("Failed outbox test. Failed deletion was not passed to the error callback.")
+    }
+    if pending, _ := outbox.Pending(0); (len(pending) != 0) {
+        test.Fatalf("Failed outbox test. Delivered message is still pending.")
+    }
+    outbox.send = func(friendNumber uint32, messageType ToxMessageType, message []byte) (uint32, error) {
+        tox.handleOutboxConnectionStatus(friendNumber, ToxConnectionNone)
+        return 9, nil
+    }
+    if err := outbox.Send(0, ToxMessageTypeNormal, []byte("lost")); err != nil {
+        test.Fatal(err)
+    }
+    if (len(outbox.inflight) != 0 || outbox.messages[0].inflight) {
+        test.Fatalf("Failed outbox test. Message lost during send is still in flight.")
+    }
+    outbox.send = func(friendNumber uint32, messageType ToxMessageType, message []byte) (uint32, error) {
+        tox.handleOutboxReadReceipt(friendNumber, 10)
+        return 10, nil
+    }
+    outbox.flush(0)
+    <-failures
+    if pending, _ := outbox.Pending(0); (len(pending) != 0 || len(outbox.receipts) != 0) {
+        test.Fatalf("Failed outbox test. Receipt during send was lost.")
+    }
+}
+
+////////////////////////////////////////////////////////////////////////////////
+////////////////////////////// PERSISTENCE TESTS ///////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+func TestFileTransferStore(test *testing.T) {
+    dir, err := ioutil.TempDir("", "tox")
+    if err != nil {
+        test.Fatal(err)
+    }
+    defer os.RemoveAll(dir)
+    store := NewFileTransferStore(filepath.Join(dir, "transfers.json"))
+    noise := rand.New(rand.NewSource(time.Now().UnixNano()))
+    input := &TransferState{}
+    noise.Read(input.FileId[:])
+    noise.Read(input.PublicKey[:])
+    input.Outgoing = noise.Intn(2) % 2 == 0
+    input.Filename = []byte("report.pdf")
+    input.Size = noise.Uint64()
+    input.Offset = noise.Uint64()
+    err = store.SaveTransfer(input)
+    if err != nil {
+        test.Fatal(err)
+    }
+    output, err := NewFileTransferStore(filepath.Join(dir, "transfers.json")).LoadTransfers()
+    if err != nil {
+        test.Fatal(err)
+    }
+    if (len(output) != 1) {
+        test.Fatalf("Failed persistence test for transfer store. Expected one state.")
+    }
+    if (output[0].FileId != input.FileId || output[0].PublicKey != input.PublicKey) {
+        test.Fatalf("Failed persistence test for transfer store. Keys do not match.")
+    }
+    if (output[0].Outgoing != input.Outgoing || output[0].Size != input.Size || output[0].Offset != input.Offset) {
+        test.Fatalf("Failed persistence test for transfer store. State does not match.")
+    }
+    if (!equal(output[0].Filename, input.Filename)) {
+        test.Fatalf("Failed persistence test for transfer store. Filename does not match.")
+    }
+    err = store.DeleteTransfer(input.FileId)
+    if err != nil {
+        test.Fatal(err)
+    }
+    output, err = store.LoadTransfers()
+    if err != nil {
+        test.Fatal(err)
+    }
+    if (len(output) != 0) {
+        test.Fatalf("Failed persistence test for transfer store. State was not deleted.")
+    }
+}
+
+func TestFileOutboxStore(test *testing.T) {
+    dir, err := ioutil.TempDir("", "tox")
+    if err != nil {
+        test.Fatal(err)
+    }
+    defer os.RemoveAll(dir)
+    store := NewFileOutboxStore(filepath.Join(dir, "outbox.json"))
+    for _, sequence := range []uint64{2, 0, 1} {
+        input := &OutboxMessage {
+            Sequence: sequence,
+            MessageType: ToxMessageTypeNormal,
+            Message: []byte{byte(sequence)},
+        }
+        err = store.SaveMessage(input)
+        if err != nil {
+            test.Fatal(err)
+        }
+    }
+    err = store.DeleteMessage(1)
+    if err != nil {
+        test.Fatal(err)
+    }
+    output, err := NewFileOutboxStore(filepath.Join(dir, "outbox.json")).LoadMessages()
+    if err != nil {
+        test.Fatal(err)
+    }
+    if (len(output) != 2 || output[0].Sequence != 0 || output[1].Sequence != 2) {
+        test.Fatalf("Failed persistence test for outbox store. Messages are missing or out of order.")
+    }
+    if (!equal(output[1].Message, []byte{2})) {
+        test.Fatalf("Failed persistence test for outbox store. Message does not match.")
+    }
+}
+
+////////////////////////////////////////////////////////////////////////////////
+/////////////////////////////// NETWORKING TESTS ///////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+func TestAddTCPRelay(test *testing.T) {
+    tox, err := New(&ToxOptions{UDPEnabled: false})
+    if err != nil {
+        test.Fatal(err)
+    }
+    defer tox.Destroy()
+    seedNode := NewSeedNode("127.0.0.1", 33445, DefaultSeedNode().PublicKey)
+    if err = tox.AddTCPRelay(seedNode); err != nil {
+        test.Fatal(err)
+    }
+    seedNode.PublicKey = "A09162D6"
+    if err = tox.AddTCPRelay(seedNode); err == nil {
+        test.Fatalf("Failed to reject TCP relay with invalid public key.")
+    }
+    seedNode = NewSeedNode("127.0.0.1", 0, DefaultSeedNode().PublicKey)
+    if err = tox.AddTCPRelay(seedNode); !errors.Is(err, ToxErrBootstrapBadPort) {
+        test.Fatalf("Failed to reject TCP relay with bad port. Got %v.", err)
+    }
+}
+
+func TestTCPRelays(test *testing.T) {
+    tox, err := New(&ToxOptions{UDPEnabled: false})
+    if err != nil {
+        test.Fatal(err)
+    }
+    defer tox.Destroy()
+    publicKey := DefaultSeedNode().PublicKey
+    relays, err := NewTCPRelays(tox, NewSeedNode("127.0.0.1", 33445, publicKey))
+    if err != nil {
+        test.Fatal(err)
+    }
+    if err = relays.Add(NewSeedNode("127.0.0.2", 443, publicKey)); err != nil {
+        test.Fatal(err)
+    }
+    if err = relays.Add(NewSeedNode("127.0.0.1", 33445, publicKey)); err != nil {
+        test.Fatal(err)
+    }
+    if (len(relays.Nodes()) != 2) {
+        test.Fatalf("Failed to replace TCP relay with the same host and port.")
+    }
+    if (!relays.Remove("127.0.0.2", 443) || relays.Remove("127.0.0.2", 443)) {
+        test.Fatalf("Failed to remove TCP relay.")
+    }
+    if err = relays.Apply(); err != nil {
+        test.Fatal(err)
+    }
+    var added []string
+    relays.add = func(seedNode *SeedNode) error {
+        added = append(added, seedNode.Host)
+        return nil
+    }
+    tox.handleRelaysConnectionStatus(ToxConnectionTCP)
+    if (len(added) != 0) {
+        test.Fatalf("Failed to keep TCP relays while connected.")
+    }
+    tox.handleRelaysConnectionStatus(ToxConnectionNone)
+    if (len(added) != 1 || added[0] != "127.0.0.1") {
+        test.Fatalf("Failed to add TCP relays again on disconnect. Added %v.", added)
+    }
+}
+
+func TestSelfNetwork(test *testing.T) {
+    options, err := DefaultOptions()
+    if err != nil {
+        test.Fatal(err)
+    }
+    noise := rand.New(rand.NewSource(time.Now().UnixNano()))
+    options.StartPort = uint16(40000 + noise.Intn(20000))
+    options.EndPort = options.StartPort + 99
+    options.TCPPort = options.EndPort + 1
+    server, err := New(options)
+    if err != nil {
+        test.Fatal(err)
+    }
+    defer server.Destroy()
+    port, err := server.GetUDPPort()
+    if err != nil {
+        test.Fatal(err)
+    }
+    if (port < options.StartPort || port > options.EndPort) {
+        test.Fatalf("UDP port %d is outside the range %d to %d.", port, options.StartPort, options.EndPort)
+    }
+    port, err = server.GetTCPPort()
+    if (err != nil || port != options.TCPPort) {
+        test.Fatalf("Failed to get TCP port. Got %d and %v.", port, err)
+    }
+    if (server.GetDHTId() == server.GetPublicKey()) {
+        test.Fatalf("DHT public key matches long-term public key.")
+    }
+    client, err := New(&ToxOptions{IPv6Enabled: true, UDPEnabled: true})
+    if err != nil {
+        test.Fatal(err)
+    }
+    defer client.Destroy()
+    if _, err = client.GetTCPPort(); !errors.Is(err, ToxErrGetPortNotBound) {
+        test.Fatalf("Failed to reject TCP port without relay server. Got %v.", err)
+    }
+    seedNode, err := server.SeedNode("127.0.0.1")
+    if err != nil {
+        test.Fatal(err)
+    }
+    if err = client.Bootstrap(seedNode); err != nil {
+        test.Fatal(err)
+    }
+    relayNode, err := server.TCPRelayNode("127.0.0.1")
+    if err != nil {
+        test.Fatal(err)
+    }
+    if err = client.AddTCPRelay(relayNode); err != nil {
+        test.Fatal(err)
+    }
+}
+
+////////////////////////////////////////////////////////////////////////////////
+//////////////////////////////// LOGGING TESTS /////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+func TestLogLevelFilter(test *testing.T) {
+    var levels []ToxLogLevel
+    sink := newLogSink(&ToxOptions {
+        Logger: func(level ToxLogLevel, file string, line uint32, function string, message string) {
+            levels = append(levels, level)
+        },
+        LogLevel: ToxLogLevelWarning,
+    })
+    for level := ToxLogLevelTrace; level <= ToxLogLevelError; level++ {
+        if (sink.enabled(level)) {
+            sink.logger(level, "network.c", 1, "networking_poll", "message")
+        }
+    }
+    if (len(levels) != 2 || levels[0] != ToxLogLevelWarning || levels[1] != ToxLogLevelError) {
+        test.Fatalf("Failed to filter log records by level. Got %v.", levels)
+    }
+    sink = newLogSink(&ToxOptions{})
+    if (sink.enabled(ToxLogLevelDebug) || !sink.enabled(ToxLogLevelInfo)) {
+        test.Fatalf("Failed to default to the info log level.")
+    }
+}
+
+func TestFinalizerLog(test *testing.T) {
+    var lock sync.Mutex
+    var messages []string
+    tox, err := New(&ToxOptions {
+        Logger: func(level ToxLogLevel, file string, line uint32, function string, message string) {
+            if (level == ToxLogLevelWarning && function == "finalize") {
+                lock.Lock()
+                messages = append(messages, message)
+                lock.Unlock()
+            }
+        },
+    })
+    if err != nil {
+        test.Fatal(err)
+    }
+    awaitFinalizer(test, tox.id)
+    lock.Lock()
+    defer lock.Unlock()
+    if (len(messages) != 1 || !strings.Contains(messages[0], "leaked")) {
+        test.Fatalf("Failed to log the destruction of a leaked instance. Got %v.", messages)
+    }
+}
+
+func TestSlogLogger(test *testing.T) {
+    var buffer bytes.Buffer
+    handler := slog.NewTextHandler(&buffer, &slog.HandlerOptions{Level: slog.LevelDebug})
+    logger := SlogLogger(slog.New(handler))
+    logger(ToxLogLevelTrace, "DHT.c", 42, "do_DHT", "trace record")
+    if (buffer.Len() != 0) {
+        test.Fatalf("Failed to log trace record below the debug level.")
+    }
+    logger(ToxLogLevelWarning, "network.c", 7, "addr_resolve", "warning record")
+    output := buffer.String()
+    for _, expected := range []string{"level=WARN", "msg=\"warning record\"", "file=network.c", "line=7", "function=addr_resolve"} {
+        if (!strings.Contains(output, expected)) {
+            test.Fatalf("Failed to log record. Missing %s in %q.", expected, output)
+        }
+    }
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 ////////////////////////////////// UTILITIES ///////////////////////////////////
 ////////////////////////////////////////////////////////////////////////////////
 
+// Wait until the leaked Tox instance with the given handle is destroyed.
+func awaitFinalizer(test *testing.T, id uintptr) {
+    for i := 0; i < 50; i++ {
+        runtime.GC()
+        instances.lock.RLock()
+        _, registered := instances.instances[id]
+        instances.lock.RUnlock()
+        if (!registered) {
+            return
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+    test.Fatalf("Failed lifecycle test. Leaked instance was not destroyed.")
+}
+
 func initialise(test *testing.T) (*Tox) {
     tox, err := New(nil)
     if err != nil {
@@ -178,6 +1494,42 @@ func initialise(test *testing.T) (*Tox) {
     return tox 
 }
 
+// This type is an in-memory io.WriterAt.
+type memoryFile struct {
+    data []byte
+}
+
+func (file *memoryFile) WriteAt(data []byte, offset int64) (int, error) {
+    if end := int(offset) + len(data); (end > len(file.data)) {
+        file.data = append(file.data, make([]byte, end - len(file.data))...)
+    }
+    return copy(file.data[offset:], data), nil
+}
+
+// This type is an in-memory outbox store whose deletions fail.
+type memoryOutboxStore struct {
+    lock     sync.Mutex
+    messages []*OutboxMessage
+    throw    error
+}
+
+func (store *memoryOutboxStore) LoadMessages() ([]*OutboxMessage, error) {
+    store.lock.Lock()
+    defer store.lock.Unlock()
+    return append([]*OutboxMessage(nil), store.messages...), nil
+}
+
+func (store *memoryOutboxStore) SaveMessage(message *OutboxMessage) error {
+    store.lock.Lock()
+    defer store.lock.Unlock()
+    store.messages = append(store.messages, message)
+    return nil
+}
+
+func (store *memoryOutboxStore) DeleteMessage(sequence uint64) error {
+    return store.throw
+}
+
 func equal(a, b []byte) bool {
     if len(a) != len(b) {
         return false
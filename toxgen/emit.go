@@ -0,0 +1,426 @@
+/**
+ * File        : emit.go
+ * Copyright   : Copyright (c) 2015-2017 Mirror Labs, Inc. All rights reserved.
+ * License     : GPLv3
+ * Maintainer  : Enzo Haussecker <enzo@mirror.co>, Dominic Williams <dominic@string.technology>
+ * Stability   : Experimental
+ * Portability : Non-portable (requires Tox core at commit dcf2aaa)
+ *
+ * This module writes the generated files. The output follows the layout of the
+ * hand-written files in the package, so that it reads the same way.
+ */
+
+package main
+
+import "bytes"
+import "fmt"
+import "regexp"
+import "strconv"
+import "strings"
+
+// The banner at the top of every generated Go file.
+const goBanner = `// Code generated by toxgen from tox.h. DO NOT EDIT.
+
+/**
+ * File        : %s
+ * Copyright   : Copyright (c) 2015-2017 Mirror Labs, Inc. All rights reserved.
+ * License     : GPLv3
+ * Maintainer  : Enzo Haussecker <enzo@mirror.co>, Dominic Williams <dominic@string.technology>
+ * Stability   : Experimental
+ * Portability : Non-portable (requires %s)
+ *
+%s */
+
+package tox
+
+`
+
+// Write a section banner.
+func section(buffer *bytes.Buffer, title string) {
+    var rule = strings.Repeat("/", 80)
+    var padding = 80 - len(title) - 2
+    var left = padding / 2
+    var right = padding - left
+    fmt.Fprintf(buffer, "%s\n%s %s %s\n%s\n\n", rule, strings.Repeat("/", left), title, strings.Repeat("/", right), rule)
+}
+
+// Wrap text into comment lines with the given prefix.
+func comment(text string, prefix string) string {
+    var lines []string
+    var line = prefix
+    for _, word := range strings.Fields(text) {
+        if (line != prefix && len(line) + 1 + len(word) > 80) {
+            lines = append(lines, line)
+            line = prefix
+        }
+        if (line == prefix) {
+            line += word
+        } else {
+            line += " " + word
+        }
+    }
+    lines = append(lines, line)
+    return strings.Join(lines, "\n") + "\n"
+}
+
+////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////////// ERRORS ///////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// The prefix of the enums that hold error codes.
+const errorPrefix = "TOX_ERR_"
+
+// The rules that assign a category to an error, by the end of its code.
+var categoryRules = []struct {
+    pattern  *regexp.Regexp
+    category string
+} {
+    {regexp.MustCompile(`PROXY_NOT_FOUND$`), "Network"},
+    {regexp.MustCompile(`NOT_FOUND$`), "NotFound"},
+    {regexp.MustCompile(`(NOT_CONNECTED|NO_CONNECTION|FAIL_SEND)$`), "Network"},
+    {regexp.MustCompile(`(MALLOC|PORT_ALLOC|SENDQ|TOO_MANY|INIT|INIT_FAIL|KEY_DERIVATION_FAILED)$`), "Resource"},
+    {regexp.MustCompile(`(OWN_KEY|ALREADY_SENT|SET_NEW_NOSPAM|NOT_PAUSED|ALREADY_PAUSED|DENIED|NOT_TRANSFERRING|DUPLICATE|NOT_BOUND)$`), "State"},
+    {regexp.MustCompile(`(NULL|TOO_LONG|NO_MESSAGE|EMPTY|INVALID|BAD_CHECKSUM|BAD_HOST|BAD_PORT|BAD_TYPE|INVALID_LENGTH|INVALID_POSITION|WRONG_TYPE|WRONG_POSITION|BAD_FORMAT|ENCRYPTED|DECRYPTION_FAILED)$`), "Argument"},
+}
+
+// This type represents an error code and the variable generated for it.
+type errorCode struct {
+    value    *Value
+    variable string
+    category string
+}
+
+// Get the error codes of an error enum, leaving out the success code.
+func errorCodes(enum *Enum) (codes []errorCode) {
+    var prefix = strings.TrimPrefix(enum.Name, errorPrefix)
+    for _, value := range enum.Values {
+        var suffix = strings.TrimPrefix(value.Name, enum.Name + "_")
+        if (suffix == "OK") {
+            continue
+        }
+        var code = errorCode {
+            value: value,
+            variable: "ToxErr" + camel(prefix) + camel(suffix),
+            category: "Unknown",
+        }
+        for _, rule := range categoryRules {
+            if (rule.pattern.MatchString(value.Name)) {
+                code.category = rule.category
+                break
+            }
+        }
+        codes = append(codes, code)
+    }
+    return
+}
+
+// The portability notes of the generated Go files.
+const (
+    portabilityCommon    = "Tox core at commit dcf2aaa"
+    portabilityToxcore02 = "c-toxcore 0.2"
+)
+
+// Generate the error variables and the functions that map codes to them. The
+// errors that only exist in c-toxcore 0.2 are left to emitToxcore02.
+func emitErrors(api *API) ([]byte, error) {
+    var buffer bytes.Buffer
+    fmt.Fprintf(&buffer, goBanner, "errors_gen.go", portabilityCommon, comment("This module defines an error for every error code in the Tox core headers, and a function for each error enum that maps its codes to these errors.", " * "))
+    for _, include := range api.Includes {
+        fmt.Fprintf(&buffer, "//#include <%s>\n", include)
+    }
+    buffer.WriteString("import \"C\"\nimport \"errors\"\n\n")
+    var enums = api.errorEnums(false)
+    section(&buffer, "ERRORS")
+    buffer.WriteString("// A collection of errors to indicate that a specific C-side error was received.\n")
+    writeErrors(&buffer, enums, "toxErrorCategories")
+    buffer.WriteString("\n")
+    section(&buffer, "ERROR MAPPING")
+    writeErrorMappings(&buffer, enums)
+    return buffer.Bytes(), nil
+}
+
+// Write the error variables of some error enums and the map that holds their
+// categories.
+func writeErrors(buffer *bytes.Buffer, enums []*Enum, categories string) {
+    var width = 0
+    for _, enum := range enums {
+        for _, code := range errorCodes(enum) {
+            if (len(code.variable) > width) {
+                width = len(code.variable)
+            }
+        }
+    }
+    buffer.WriteString("var (\n\n")
+    for _, enum := range enums {
+        for _, code := range errorCodes(enum) {
+            var doc = code.value.Doc
+            if (doc == "") {
+                doc = code.value.Name
+            }
+            fmt.Fprintf(buffer, "    %-*s = errors.New(%s)\n", width, code.variable, strconv.Quote(doc))
+        }
+    }
+    buffer.WriteString("\n)\n\n")
+    fmt.Fprintf(buffer, "// The category of each sentinel error that corresponds to a C-side error.\nvar %s = map[error]ToxErrorCategory {\n", categories)
+    for _, enum := range enums {
+        for _, code := range errorCodes(enum) {
+            if (code.category != "Unknown") {
+                fmt.Fprintf(buffer, "    %-*s ToxErrorCategory%s,\n", width + 1, code.variable + ":", code.category)
+            }
+        }
+    }
+    buffer.WriteString("}\n")
+}
+
+// Write the functions that map the codes of some error enums to errors.
+func writeErrorMappings(buffer *bytes.Buffer, enums []*Enum) {
+    for i, enum := range enums {
+        var name = "error" + camel(strings.TrimPrefix(enum.Name, errorPrefix))
+        fmt.Fprintf(buffer, "// Map a %s code to an error.\n", enum.Name)
+        fmt.Fprintf(buffer, "func %s(op string, c_error C.%s) error {\n", name, enum.Name)
+        buffer.WriteString("    var throw error\n    switch c_error {\n")
+        for _, code := range errorCodes(enum) {
+            fmt.Fprintf(buffer, "        case C.%s:\n            throw = %s\n", code.value.Name, code.variable)
+        }
+        buffer.WriteString("        default:\n            throw = ToxErrUnknown\n    }\n")
+        buffer.WriteString("    return newToxError(op, int(c_error), throw)\n}\n")
+        if (i < len(enums) - 1) {
+            buffer.WriteString("\n")
+        }
+    }
+}
+
+////////////////////////////////////////////////////////////////////////////////
+/////////////////////////////////// CALLBACKS //////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// This type describes how a C enum passed to a callback maps to a Go enum.
+type enumMapping struct {
+    goType   string
+    prefix   string
+    variable string
+    unknown  string
+}
+
+// The C enums that are passed to callbacks.
+var enumMappings = map[string]enumMapping {
+    "TOX_CONNECTION": {"ToxConnectionStatus", "ToxConnection", "connectionStatus", "unknown connection status"},
+    "TOX_USER_STATUS": {"ToxUserStatus", "ToxUserStatus", "userStatus", "unknown user status"},
+    "TOX_MESSAGE_TYPE": {"ToxMessageType", "ToxMessageType", "messageType", "unknown message type"},
+    "TOX_FILE_CONTROL": {"ToxFileControl", "ToxFileControl", "control", "unknown file control"},
+    "TOX_CONFERENCE_TYPE": {"ToxConferenceType", "ToxConferenceType", "conferenceType", "unknown conference type"},
+}
+
+// The integer parameters that have a more specific Go type.
+var integerTypes = map[string]string {
+    "kind": "ToxFileKind",
+}
+
+// The pointer parameters that point to fixed-size arrays.
+var arrayTypes = map[string][2]string {
+    "public_key": {"ToxPublicKey", "ToxPublicKeySize"},
+}
+
+// Generate the callback declarations and registration functions.
+func emitCallbacksHeader(api *API) ([]byte, error) {
+    var buffer bytes.Buffer
+    buffer.WriteString("// Code generated by toxgen from tox.h. DO NOT EDIT.\n\n")
+    buffer.WriteString("/**\n * File        : callbacks.h\n * Copyright   : Copyright (c) 2015-2017 Mirror Labs, Inc. All rights reserved.\n * License     : GPLv3\n * Maintainer  : Enzo Haussecker <enzo@mirror.co>, Dominic Williams <dominic@string.technology>\n * Stability   : Experimental\n * Portability : Non-portable (requires Tox core at commit dcf2aaa)\n */\n\n")
+    buffer.WriteString("#include <stdint.h>\n#include <stdlib.h>\n#include <tox/tox.h>\n#include \"compat.h\"\n\n")
+    writeCallbackDeclarations(&buffer, api.callbacks(false))
+    buffer.WriteString("#ifdef TOXCORE_02\n")
+    writeCallbackDeclarations(&buffer, api.callbacks(true))
+    buffer.WriteString("#endif\n")
+    buffer.WriteString(`
+// We cannot register our callbacks directly from Go. This macro creates a C
+// function that registers a pointer to our callback function defined in Go.
+// The user data is the registry handle of the Tox instance, not a Go pointer.
+// In c-toxcore 0.2, the user data is passed to tox_iterate instead, so the
+// handle is not used here.
+#ifdef TOXCORE_02
+#define GEN_CALLBACK_API(x) \
+static void register_##x(Tox *tox, uintptr_t t) { \
+    tox_callback_##x(tox, callback_##x); \
+}
+#else
+#define GEN_CALLBACK_API(x) \
+static void register_##x(Tox *tox, uintptr_t t) { \
+    tox_callback_##x(tox, callback_##x, (void *) t); \
+}
+#endif
+
+`)
+    for _, callback := range api.callbacks(false) {
+        fmt.Fprintf(&buffer, "GEN_CALLBACK_API(%s)\n", callback.Name)
+    }
+    buffer.WriteString("#ifdef TOXCORE_02\n")
+    for _, callback := range api.callbacks(true) {
+        fmt.Fprintf(&buffer, "GEN_CALLBACK_API(%s)\n", callback.Name)
+    }
+    buffer.WriteString("#endif\n")
+    return buffer.Bytes(), nil
+}
+
+// Write the declarations of the hooks of some callbacks.
+func writeCallbackDeclarations(buffer *bytes.Buffer, callbacks []*Callback) {
+    for _, callback := range callbacks {
+        var types []string
+        for _, param := range callback.Params {
+            if (param.Type == "Tox *") {
+                types = append(types, "struct Tox *")
+            } else {
+                types = append(types, param.Type)
+            }
+        }
+        fmt.Fprintf(buffer, "void callback_%s(%s);\n", callback.Name, strings.Join(types, ", "))
+    }
+}
+
+// Generate the callback hooks. The hooks of the callbacks that only exist in
+// c-toxcore 0.2 are left to emitToxcore02.
+func emitCallbacks(api *API) ([]byte, error) {
+    var buffer bytes.Buffer
+    fmt.Fprintf(&buffer, goBanner, "callbacks.go", portabilityCommon, comment("Tox instances handle events using callback functions. The hooks in this module copy the arguments of each callback into Go values and pass them to the dispatch method of the instance, which is written by hand.", " * "))
+    buffer.WriteString("//#include <memory.h>\n//#include <tox/tox.h>\nimport \"C\"\nimport \"unsafe\"\n\n")
+    section(&buffer, "CALLBACK HOOKS")
+    var throw = writeHooks(&buffer, api, api.callbacks(false))
+    if throw != nil {
+        return nil, throw
+    }
+    return buffer.Bytes(), nil
+}
+
+// Write the hooks of some callbacks.
+func writeHooks(buffer *bytes.Buffer, api *API, callbacks []*Callback) error {
+    for i, callback := range callbacks {
+        hook, throw := emitHook(api, callback)
+        if throw != nil {
+            return fmt.Errorf("callback %s: %v", callback.Name, throw)
+        }
+        buffer.WriteString(hook)
+        if (i < len(callbacks) - 1) {
+            buffer.WriteString("\n")
+        }
+    }
+    return nil
+}
+
+// Generate the hook of a callback.
+func emitHook(api *API, callback *Callback) (string, error) {
+    var signature []string
+    var body bytes.Buffer
+    var args []string
+    var params = callback.Params
+    for i := 0; i < len(params); i++ {
+        var param = params[i]
+        var c_name = "c_" + param.Name
+        var name = lowerCamel(param.Name)
+        switch {
+            case param.Type == "Tox *" && i == 0:
+                signature = append(signature, "c_tox *C.Tox")
+            case param.Type == "void *" && i == len(params) - 1:
+                signature = append(signature, "c_user_data unsafe.Pointer")
+            case param.Type == "const uint8_t *" && i + 1 < len(params) && params[i + 1].Type == "size_t":
+                var c_length = "c_" + params[i + 1].Name
+                signature = append(signature, c_name + " *C.uint8_t", c_length + " C.size_t")
+                fmt.Fprintf(&body, "    %s := make([]byte, %s)\n", name, c_length)
+                fmt.Fprintf(&body, "    if (%s > 0) {\n        C.memcpy(\n            unsafe.Pointer(&%s[0]),\n            unsafe.Pointer(%s),\n            %s,\n        )\n    }\n", c_length, name, c_name, c_length)
+                args = append(args, name)
+                i++
+            case param.Type == "const uint8_t *":
+                array, ok := arrayTypes[param.Name]
+                if (!ok) {
+                    return "", fmt.Errorf("unsupported pointer parameter %s", param.Name)
+                }
+                signature = append(signature, c_name + " *C.uint8_t")
+                fmt.Fprintf(&body, "    var %s %s\n", name, array[0])
+                fmt.Fprintf(&body, "    C.memcpy(\n        unsafe.Pointer(&%s[0]),\n        unsafe.Pointer(%s),\n        %s,\n    )\n", name, c_name, array[1])
+                args = append(args, name)
+            case param.Type == "uint32_t" || param.Type == "uint64_t":
+                var goType = strings.TrimSuffix(param.Type, "_t")
+                if override, ok := integerTypes[param.Name]; ok {
+                    goType = override
+                }
+                signature = append(signature, c_name + " C." + param.Type)
+                fmt.Fprintf(&body, "    %s := %s(%s)\n", name, goType, c_name)
+                args = append(args, name)
+            case param.Type == "size_t":
+                signature = append(signature, c_name + " C.size_t")
+                fmt.Fprintf(&body, "    %s := int(%s)\n", name, c_name)
+                args = append(args, name)
+            case param.Type == "bool":
+                signature = append(signature, c_name + " C.bool")
+                fmt.Fprintf(&body, "    %s := bool(%s)\n", name, c_name)
+                args = append(args, name)
+            default:
+                mapping, ok := enumMappings[param.Type]
+                var enum = api.enum(param.Type)
+                if (!ok || enum == nil) {
+                    return "", fmt.Errorf("unsupported parameter type %s", param.Type)
+                }
+                signature = append(signature, c_name + " C." + param.Type)
+                fmt.Fprintf(&body, "    var %s %s\n    switch %s {\n", mapping.variable, mapping.goType, c_name)
+                for _, value := range enum.Values {
+                    var suffix = strings.TrimPrefix(value.Name, enum.Name + "_")
+                    fmt.Fprintf(&body, "        case C.%s:\n            %s = %s%s\n", value.Name, mapping.variable, mapping.prefix, camel(suffix))
+                }
+                fmt.Fprintf(&body, "        default:\n            panic(%q)\n    }\n", mapping.unknown)
+                args = append(args, mapping.variable)
+        }
+    }
+    var hook bytes.Buffer
+    fmt.Fprintf(&hook, "//export callback_%s\nfunc callback_%s(\n", callback.Name, callback.Name)
+    for _, field := range signature {
+        fmt.Fprintf(&hook, "    %s,\n", field)
+    }
+    hook.WriteString(") {\n    tox := instances.lookup(c_user_data)\n    if (tox == nil) {\n        return\n    }\n")
+    hook.Write(body.Bytes())
+    fmt.Fprintf(&hook, "    tox.dispatch%s(%s)\n}\n", camel(strings.ToUpper(callback.Name)), strings.Join(args, ", "))
+    return hook.String(), nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+//////////////////////////////// C-TOXCORE 0.2 /////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Generate the errors, error mappings and callback hooks of the parts of the
+// API that only exist in c-toxcore 0.2, behind the toxcore02 build tag. This
+// returns nil if the header declares none, as the header of commit dcf2aaa
+// does, in which case the file is left as it is.
+func emitToxcore02(api *API) ([]byte, error) {
+    var enums = api.errorEnums(true)
+    var callbacks = api.callbacks(true)
+    if (len(enums) == 0 && len(callbacks) == 0) {
+        return nil, nil
+    }
+    var buffer bytes.Buffer
+    buffer.WriteString("//go:build toxcore02\n\n")
+    fmt.Fprintf(&buffer, goBanner, "toxcore02_gen.go", portabilityToxcore02, comment("This module defines the errors, error mappings and callback hooks of the parts of the API that only exist in c-toxcore 0.2, such as conferences.", " * "))
+    buffer.WriteString("//#include <memory.h>\n//#include <tox/tox.h>\nimport \"C\"\n")
+    if (len(enums) > 0) {
+        buffer.WriteString("import \"errors\"\n")
+    }
+    if (len(callbacks) > 0) {
+        buffer.WriteString("import \"unsafe\"\n")
+    }
+    buffer.WriteString("\n")
+    if (len(enums) > 0) {
+        section(&buffer, "ERRORS")
+        buffer.WriteString("// A collection of errors to indicate that a specific C-side error was received.\n")
+        writeErrors(&buffer, enums, "toxcore02ErrorCategories")
+        buffer.WriteString("\n// Add the categories of these errors to those of the common errors.\nfunc init() {\n    for err, category := range toxcore02ErrorCategories {\n        toxErrorCategories[err] = category\n    }\n}\n\n")
+        section(&buffer, "ERROR MAPPING")
+        writeErrorMappings(&buffer, enums)
+    }
+    if (len(callbacks) > 0) {
+        if (len(enums) > 0) {
+            buffer.WriteString("\n")
+        }
+        section(&buffer, "CALLBACK HOOKS")
+        var throw = writeHooks(&buffer, api, callbacks)
+        if throw != nil {
+            return nil, throw
+        }
+    }
+    return buffer.Bytes(), nil
+}
@@ -0,0 +1,107 @@
+/**
+ * File        : main.go
+ * Copyright   : Copyright (c) 2015-2017 Mirror Labs, Inc. All rights reserved.
+ * License     : GPLv3
+ * Maintainer  : Enzo Haussecker <enzo@mirror.co>, Dominic Williams <dominic@string.technology>
+ * Stability   : Experimental
+ * Portability : Non-portable (requires Tox core at commit dcf2aaa)
+ *
+ * This program generates the mechanical parts of the bindings from the Tox
+ * core header: the error variables and the functions that map error codes to
+ * them, including those of toxencryptsave.h if it is found next to tox.h, the
+ * callback declarations and registration functions in callbacks.h,
+ * and the callback hooks in callbacks.go. The hooks convert the arguments of
+ * each callback and pass them to a hand-written dispatch method, so a new
+ * callback in the header requires a dispatch method before the package builds
+ * again. The parts of the API that only exist in c-toxcore 0.2 go to
+ * toxcore02_gen.go behind the toxcore02 build tag, and to a conditional
+ * section of callbacks.h, so the header of c-toxcore 0.2 generates the files
+ * for both versions. It is run by go generate from the package directory.
+ */
+
+package main
+
+import "flag"
+import "fmt"
+import "io/ioutil"
+import "os"
+import "path/filepath"
+import "strings"
+
+// The locations searched for the header if none is given.
+var defaultHeaders = []string {
+    "/usr/local/include/tox/tox.h",
+    "/usr/include/tox/tox.h",
+}
+
+func main() {
+    var header = flag.String("header", "", "path to tox.h; searched for in the default include directories if empty")
+    var output = flag.String("output", ".", "directory to write the generated files to")
+    var skip = flag.String("skip", "", "comma-separated callbacks to leave out, e.g. friend_lossy_packet")
+    flag.Parse()
+    var throw = generate(*header, *output, *skip)
+    if throw != nil {
+        fmt.Fprintln(os.Stderr, "toxgen:", throw)
+        os.Exit(1)
+    }
+}
+
+// Generate the bindings from a header into a directory.
+func generate(header string, output string, skip string) (throw error) {
+    if (header == "") {
+        header, throw = findHeader()
+        if throw != nil {
+            return
+        }
+    }
+    source, throw := ioutil.ReadFile(header)
+    if throw != nil {
+        return
+    }
+    api, throw := parse(string(source))
+    if throw != nil {
+        return
+    }
+    if (skip != "") {
+        api.skip(strings.Split(skip, ","))
+    }
+    source, throw = ioutil.ReadFile(filepath.Join(filepath.Dir(header), "toxencryptsave.h"))
+    if (throw == nil) {
+        throw = api.parseExtra("tox/toxencryptsave.h", string(source))
+    } else if os.IsNotExist(throw) {
+        throw = nil
+    }
+    if throw != nil {
+        return
+    }
+    var files = map[string]func(*API) ([]byte, error) {
+        "errors_gen.go": emitErrors,
+        "callbacks.h": emitCallbacksHeader,
+        "callbacks.go": emitCallbacks,
+        "toxcore02_gen.go": emitToxcore02,
+    }
+    for name, emit := range files {
+        data, throw := emit(api)
+        if throw != nil {
+            return fmt.Errorf("%s: %v", name, throw)
+        }
+        if (data == nil) {
+            continue
+        }
+        throw = ioutil.WriteFile(filepath.Join(output, name), data, 0644)
+        if throw != nil {
+            return throw
+        }
+    }
+    return
+}
+
+// Find the header in the default include directories.
+func findHeader() (string, error) {
+    for _, path := range defaultHeaders {
+        if _, throw := os.Stat(path); throw == nil {
+            return path, nil
+        }
+    }
+    return "", fmt.Errorf("cannot find tox.h; pass its location with -header")
+}
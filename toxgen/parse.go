@@ -0,0 +1,249 @@
+/**
+ * File        : parse.go
+ * Copyright   : Copyright (c) 2015-2017 Mirror Labs, Inc. All rights reserved.
+ * License     : GPLv3
+ * Maintainer  : Enzo Haussecker <enzo@mirror.co>, Dominic Williams <dominic@string.technology>
+ * Stability   : Experimental
+ * Portability : Non-portable (requires Tox core at commit dcf2aaa)
+ *
+ * This module extracts the enums and callback types from the Tox core header.
+ * It does not implement a C parser. It relies on the regular layout of the
+ * header, in which every enum is a typedef with a documentation comment before
+ * each value, and every callback type is a typedef of a function returning
+ * void.
+ */
+
+package main
+
+import "fmt"
+import "regexp"
+import "strings"
+
+////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////// STRUCT TYPES /////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// This type holds the declarations extracted from the headers, along with the
+// headers to include in the generated files.
+type API struct {
+    Includes  []string
+    Enums     []*Enum
+    Callbacks []*Callback
+}
+
+// This type represents an enum.
+type Enum struct {
+    Name   string
+    Values []*Value
+}
+
+// This type represents a value of an enum, along with its documentation.
+type Value struct {
+    Name string
+    Doc  string
+}
+
+// This type represents a callback that can be registered with a Tox instance.
+type Callback struct {
+    Name   string
+    Params []*Param
+}
+
+// This type represents a parameter of a callback.
+type Param struct {
+    Type string
+    Name string
+}
+
+////////////////////////////////////////////////////////////////////////////////
+//////////////////////////////////// PARSING ///////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+var enumPattern = regexp.MustCompile(`(?s)typedef\s+enum\s+(\w+)\s*\{(.*?)\}\s*(\w+)\s*;`)
+var valuePattern = regexp.MustCompile(`(?s)(?:/\*\*(.*?)\*/\s*)?([A-Z][A-Z0-9_]*)\s*(?:=[^,]*)?\s*(?:,|$)`)
+var callbackPattern = regexp.MustCompile(`typedef\s+void\s+tox_(\w+)_cb\s*\(([^)]*)\)\s*;`)
+var registerPattern = regexp.MustCompile(`\btox_callback_(\w+)\s*\(`)
+var commentPattern = regexp.MustCompile(`(?s)/\*.*?\*/|//[^\n]*`)
+
+// Parse the header.
+func parse(source string) (api *API, throw error) {
+    api = &API{Includes: []string{"tox/tox.h"}}
+    api.Enums = parseEnums(source)
+    var stripped = commentPattern.ReplaceAllString(source, "")
+    var registered = make(map[string]bool)
+    for _, match := range registerPattern.FindAllStringSubmatch(stripped, -1) {
+        registered[match[1]] = true
+    }
+    for _, match := range callbackPattern.FindAllStringSubmatch(stripped, -1) {
+        if (!registered[match[1]]) {
+            continue
+        }
+        var callback = &Callback{Name: match[1]}
+        for _, field := range strings.Split(match[2], ",") {
+            param, throw := parseParam(field)
+            if throw != nil {
+                return nil, fmt.Errorf("callback %s: %v", callback.Name, throw)
+            }
+            callback.Params = append(callback.Params, param)
+        }
+        api.Callbacks = append(api.Callbacks, callback)
+    }
+    if (len(api.Enums) == 0 || len(api.Callbacks) == 0) {
+        return nil, fmt.Errorf("no enums or callbacks found; is this tox.h?")
+    }
+    return
+}
+
+// Parse an additional header, such as toxencryptsave.h, that declares only
+// enums. The header is included in the generated files.
+func (api *API) parseExtra(include string, source string) (throw error) {
+    var enums = parseEnums(source)
+    if (len(enums) == 0) {
+        return fmt.Errorf("no enums found in %s", include)
+    }
+    api.Includes = append(api.Includes, include)
+    api.Enums = append(api.Enums, enums...)
+    return
+}
+
+// Parse the enums of a header.
+func parseEnums(source string) (enums []*Enum) {
+    for _, match := range enumPattern.FindAllStringSubmatch(source, -1) {
+        if (match[1] != match[3]) {
+            continue
+        }
+        var enum = &Enum{Name: match[1]}
+        for _, value := range valuePattern.FindAllStringSubmatch(match[2], -1) {
+            enum.Values = append(enum.Values, &Value {
+                Name: value[2],
+                Doc: cleanDoc(value[1]),
+            })
+        }
+        enums = append(enums, enum)
+    }
+    return
+}
+
+// Parse a parameter declaration such as "const uint8_t *message".
+func parseParam(field string) (*Param, error) {
+    field = strings.Join(strings.Fields(field), " ")
+    var split = strings.LastIndexAny(field, " *")
+    if (split < 0 || split == len(field) - 1) {
+        return nil, fmt.Errorf("cannot parse parameter %q", field)
+    }
+    var kind = strings.TrimSpace(field[:split + 1])
+    kind = strings.Replace(kind, " *", "*", -1)
+    kind = strings.Replace(kind, "*", " *", -1)
+    return &Param{Type: kind, Name: field[split + 1:]}, nil
+}
+
+// Collapse a documentation comment into a single line.
+func cleanDoc(doc string) string {
+    var words []string
+    for _, line := range strings.Split(doc, "\n") {
+        line = strings.TrimSpace(line)
+        line = strings.TrimPrefix(line, "*")
+        words = append(words, strings.Fields(line)...)
+    }
+    return strings.Join(words, " ")
+}
+
+// Look up an enum by name.
+func (api *API) enum(name string) *Enum {
+    for _, enum := range api.Enums {
+        if (enum.Name == name) {
+            return enum
+        }
+    }
+    return nil
+}
+
+// The prefixes of the declarations that only exist in c-toxcore 0.2. At commit
+// dcf2aaa, group chats belong to the old API in tox_old.h, which these bindings
+// do not wrap.
+var toxcore02Prefixes = []string {
+    "TOX_ERR_CONFERENCE_",
+    "TOX_CONFERENCE_",
+    "conference_",
+}
+
+// Check whether a declaration only exists in c-toxcore 0.2. Such declarations
+// are generated behind the toxcore02 build tag.
+func toxcore02Only(name string) bool {
+    for _, prefix := range toxcore02Prefixes {
+        if (strings.HasPrefix(name, prefix)) {
+            return true
+        }
+    }
+    return false
+}
+
+// Get the error enums, either those common to both versions of the core or
+// those that only exist in c-toxcore 0.2.
+func (api *API) errorEnums(toxcore02 bool) (enums []*Enum) {
+    for _, enum := range api.Enums {
+        if (strings.HasPrefix(enum.Name, errorPrefix) && toxcore02Only(enum.Name) == toxcore02) {
+            enums = append(enums, enum)
+        }
+    }
+    return
+}
+
+// Get the callbacks, either those common to both versions of the core or those
+// that only exist in c-toxcore 0.2.
+func (api *API) callbacks(toxcore02 bool) (callbacks []*Callback) {
+    for _, callback := range api.Callbacks {
+        if (toxcore02Only(callback.Name) == toxcore02) {
+            callbacks = append(callbacks, callback)
+        }
+    }
+    return
+}
+
+// Remove the named callbacks.
+func (api *API) skip(names []string) {
+    var skipped = make(map[string]bool)
+    for _, name := range names {
+        skipped[strings.TrimSpace(name)] = true
+    }
+    var callbacks []*Callback
+    for _, callback := range api.Callbacks {
+        if (!skipped[callback.Name]) {
+            callbacks = append(callbacks, callback)
+        }
+    }
+    api.Callbacks = callbacks
+}
+
+// Convert an upper-case C identifier such as FRIEND_SEND_MESSAGE into Go form
+// such as FriendSendMessage.
+func camel(name string) string {
+    var result string
+    for _, word := range strings.Split(name, "_") {
+        if (word == "") {
+            continue
+        }
+        if acronym, ok := acronyms[word]; ok {
+            result += acronym
+        } else {
+            result += word[:1] + strings.ToLower(word[1:])
+        }
+    }
+    return result
+}
+
+// Convert a lower-case C identifier such as friend_number into Go form such as
+// friendNumber.
+func lowerCamel(name string) string {
+    var result = camel(strings.ToUpper(name))
+    return strings.ToLower(result[:1]) + result[1:]
+}
+
+// Words that are not simply capitalized in Go identifiers.
+var acronyms = map[string]string {
+    "AV": "AV",
+    "NOSPAM": "NoSpam",
+    "SENDQ": "SendQ",
+    "TCP": "TCP",
+    "UDP": "UDP",
+}
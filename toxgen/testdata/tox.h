@@ -0,0 +1,76 @@
+/* An excerpt of tox.h used to test the generator. */
+
+typedef struct Tox Tox;
+
+/**
+ * Protocols that can be used to connect to the network or friends.
+ */
+typedef enum TOX_CONNECTION {
+
+    /**
+     * There is no connection.
+     */
+    TOX_CONNECTION_NONE,
+
+    /**
+     * A TCP connection has been established.
+     */
+    TOX_CONNECTION_TCP,
+
+    /**
+     * A UDP connection has been established.
+     */
+    TOX_CONNECTION_UDP,
+
+} TOX_CONNECTION;
+
+typedef enum TOX_ERR_FRIEND_SEND_MESSAGE {
+
+    /**
+     * The function returned successfully.
+     */
+    TOX_ERR_FRIEND_SEND_MESSAGE_OK,
+
+    /**
+     * The friend number did not designate a valid friend.
+     */
+    TOX_ERR_FRIEND_SEND_MESSAGE_FRIEND_NOT_FOUND,
+
+    /**
+     * An allocation error occurred while increasing the send queue size.
+     */
+    TOX_ERR_FRIEND_SEND_MESSAGE_SENDQ,
+
+    /**
+     * Attempted to send a zero-length message.
+     */
+    TOX_ERR_FRIEND_SEND_MESSAGE_EMPTY,
+
+} TOX_ERR_FRIEND_SEND_MESSAGE;
+
+/**
+ * @param friend_number The friend number of the friend whose connection status
+ *   changed.
+ * @param connection_status The result of calling
+ *   tox_friend_get_connection_status on the passed friend_number.
+ */
+typedef void tox_friend_connection_status_cb(Tox *tox, uint32_t friend_number, TOX_CONNECTION connection_status,
+        void *user_data);
+
+void tox_callback_friend_connection_status(Tox *tox, tox_friend_connection_status_cb *callback, void *user_data);
+
+/**
+ * @param friend_number The friend number of the friend who sent the message.
+ * @param message The message data they sent.
+ * @param length The size of the message byte array.
+ */
+typedef void tox_friend_status_message_cb(Tox *tox, uint32_t friend_number, const uint8_t *message, size_t length,
+        void *user_data);
+
+void tox_callback_friend_status_message(Tox *tox, tox_friend_status_message_cb *callback, void *user_data);
+
+/**
+ * A callback type without a registration function is not a callback that can
+ * be hooked.
+ */
+typedef void tox_log_cb(Tox *tox, uint32_t level, const char *file, void *user_data);
@@ -0,0 +1,243 @@
+/**
+ * File        : toxgen_test.go
+ * Copyright   : Copyright (c) 2015-2017 Mirror Labs, Inc. All rights reserved.
+ * License     : GPLv3
+ * Maintainer  : Enzo Haussecker <enzo@mirror.co>, Dominic Williams <dominic@string.technology>
+ * Stability   : Experimental
+ * Portability : Non-portable (requires Tox core at commit dcf2aaa)
+ */
+
+package main
+
+import "io/ioutil"
+import "strings"
+import "testing"
+
+func loadFixture(test *testing.T) *API {
+    source, err := ioutil.ReadFile("testdata/tox.h")
+    if err != nil {
+        test.Fatal(err)
+    }
+    api, err := parse(string(source))
+    if err != nil {
+        test.Fatal(err)
+    }
+    return api
+}
+
+func TestParse(test *testing.T) {
+    api := loadFixture(test)
+    if (len(api.Enums) != 2) {
+        test.Fatalf("Failed to parse enums. Expected 2 enums, got %d.", len(api.Enums))
+    }
+    enum := api.enum("TOX_ERR_FRIEND_SEND_MESSAGE")
+    if (enum == nil || len(enum.Values) != 4) {
+        test.Fatalf("Failed to parse enum values.")
+    }
+    if (enum.Values[2].Name != "TOX_ERR_FRIEND_SEND_MESSAGE_SENDQ") {
+        test.Fatalf("Failed to parse enum value name. Got %s.", enum.Values[2].Name)
+    }
+    if (enum.Values[2].Doc != "An allocation error occurred while increasing the send queue size.") {
+        test.Fatalf("Failed to parse enum value documentation. Got %q.", enum.Values[2].Doc)
+    }
+    if (len(api.Callbacks) != 2) {
+        test.Fatalf("Failed to parse callbacks. Expected 2 callbacks, got %d.", len(api.Callbacks))
+    }
+    callback := api.Callbacks[1]
+    if (callback.Name != "friend_status_message" || len(callback.Params) != 5) {
+        test.Fatalf("Failed to parse callback %s.", callback.Name)
+    }
+    if (callback.Params[2].Type != "const uint8_t *" || callback.Params[2].Name != "message") {
+        test.Fatalf("Failed to parse callback parameter. Got %q %q.", callback.Params[2].Type, callback.Params[2].Name)
+    }
+}
+
+func TestEmitErrors(test *testing.T) {
+    api := loadFixture(test)
+    data, err := emitErrors(api)
+    if err != nil {
+        test.Fatal(err)
+    }
+    output := string(data)
+    expected := []string {
+        `ToxErrFriendSendMessageSendQ          = errors.New("An allocation error occurred while increasing the send queue size.")`,
+        `ToxErrFriendSendMessageFriendNotFound: ToxErrorCategoryNotFound,`,
+        `ToxErrFriendSendMessageSendQ:          ToxErrorCategoryResource,`,
+        `func errorFriendSendMessage(op string, c_error C.TOX_ERR_FRIEND_SEND_MESSAGE) error {`,
+        "case C.TOX_ERR_FRIEND_SEND_MESSAGE_EMPTY:\n            throw = ToxErrFriendSendMessageEmpty\n",
+    }
+    for _, line := range expected {
+        if (!strings.Contains(output, line)) {
+            test.Fatalf("Failed to generate errors. Missing %q.", line)
+        }
+    }
+    if (strings.Contains(output, "ToxErrFriendSendMessageOk")) {
+        test.Fatalf("Failed to generate errors. The success code has an error.")
+    }
+}
+
+func TestEmitCallbacks(test *testing.T) {
+    api := loadFixture(test)
+    data, err := emitCallbacksHeader(api)
+    if err != nil {
+        test.Fatal(err)
+    }
+    header := string(data)
+    if (!strings.Contains(header, "void callback_friend_status_message(struct Tox *, uint32_t, const uint8_t *, size_t, void *);")) {
+        test.Fatalf("Failed to generate callback declaration.")
+    }
+    if (!strings.Contains(header, "GEN_CALLBACK_API(friend_connection_status)\n")) {
+        test.Fatalf("Failed to generate callback registration.")
+    }
+    if (!strings.Contains(header, "#ifdef TOXCORE_02\n")) {
+        test.Fatalf("Failed to generate callback registration for c-toxcore 0.2.")
+    }
+    if (strings.Contains(header, "callback_log")) {
+        test.Fatalf("Failed to skip callback type without a registration function.")
+    }
+    data, err = emitCallbacks(api)
+    if err != nil {
+        test.Fatal(err)
+    }
+    hooks := string(data)
+    expected := []string {
+        "//export callback_friend_connection_status\n",
+        "        case C.TOX_CONNECTION_UDP:\n            connectionStatus = ToxConnectionUDP\n",
+        "    tox.dispatchFriendConnectionStatus(friendNumber, connectionStatus)\n",
+        "    message := make([]byte, c_length)\n",
+        "    tox.dispatchFriendStatusMessage(friendNumber, message)\n",
+    }
+    for _, line := range expected {
+        if (!strings.Contains(hooks, line)) {
+            test.Fatalf("Failed to generate callback hooks. Missing %q.", line)
+        }
+    }
+}
+
+func TestParseExtra(test *testing.T) {
+    api := loadFixture(test)
+    err := api.parseExtra("tox/toxencryptsave.h", `
+typedef enum TOX_ERR_KEY_DERIVATION {
+    TOX_ERR_KEY_DERIVATION_OK,
+    /**
+     * The crypto lib was unable to derive a key from the given passphrase.
+     */
+    TOX_ERR_KEY_DERIVATION_FAILED,
+} TOX_ERR_KEY_DERIVATION;
+`)
+    if err != nil {
+        test.Fatal(err)
+    }
+    data, err := emitErrors(api)
+    if err != nil {
+        test.Fatal(err)
+    }
+    output := string(data)
+    if (!strings.Contains(output, "//#include <tox/toxencryptsave.h>\n")) {
+        test.Fatalf("Failed to include additional header.")
+    }
+    if (!strings.Contains(output, "ToxErrKeyDerivationFailed:             ToxErrorCategoryResource,")) {
+        test.Fatalf("Failed to generate errors of additional header.")
+    }
+    if (api.parseExtra("tox/empty.h", "") == nil) {
+        test.Fatalf("Failed to reject header without enums.")
+    }
+}
+
+func TestEmitToxcore02(test *testing.T) {
+    api := loadFixture(test)
+    data, err := emitToxcore02(api)
+    if (err != nil || data != nil) {
+        test.Fatalf("Failed to leave out the c-toxcore 0.2 file of a header without its declarations.")
+    }
+    conference, err := parse(`
+typedef enum TOX_ERR_CONFERENCE_DELETE {
+    TOX_ERR_CONFERENCE_DELETE_OK,
+    /**
+     * The conference number passed did not designate a valid conference.
+     */
+    TOX_ERR_CONFERENCE_DELETE_CONFERENCE_NOT_FOUND,
+} TOX_ERR_CONFERENCE_DELETE;
+
+typedef void tox_conference_peer_list_changed_cb(Tox *tox, uint32_t conference_number, void *user_data);
+
+void tox_callback_conference_peer_list_changed(Tox *tox, tox_conference_peer_list_changed_cb *callback);
+`)
+    if err != nil {
+        test.Fatal(err)
+    }
+    api.Enums = append(api.Enums, conference.Enums...)
+    api.Callbacks = append(api.Callbacks, conference.Callbacks...)
+    data, err = emitErrors(api)
+    if err != nil {
+        test.Fatal(err)
+    }
+    if (strings.Contains(string(data), "Conference")) {
+        test.Fatalf("Failed to leave out the c-toxcore 0.2 errors of the common errors.")
+    }
+    data, err = emitCallbacks(api)
+    if err != nil {
+        test.Fatal(err)
+    }
+    if (strings.Contains(string(data), "conference")) {
+        test.Fatalf("Failed to leave out the c-toxcore 0.2 hooks of the common hooks.")
+    }
+    data, err = emitCallbacksHeader(api)
+    if err != nil {
+        test.Fatal(err)
+    }
+    header := string(data)
+    expected := []string {
+        "#ifdef TOXCORE_02\nvoid callback_conference_peer_list_changed(struct Tox *, uint32_t, void *);\n#endif\n",
+        "#ifdef TOXCORE_02\nGEN_CALLBACK_API(conference_peer_list_changed)\n#endif\n",
+    }
+    for _, line := range expected {
+        if (!strings.Contains(header, line)) {
+            test.Fatalf("Failed to generate c-toxcore 0.2 callback declarations. Missing %q.", line)
+        }
+    }
+    data, err = emitToxcore02(api)
+    if err != nil {
+        test.Fatal(err)
+    }
+    output := string(data)
+    if (!strings.HasPrefix(output, "//go:build toxcore02\n\n")) {
+        test.Fatalf("Failed to generate the build tag of the c-toxcore 0.2 file.")
+    }
+    expected = []string {
+        `ToxErrConferenceDeleteConferenceNotFound: ToxErrorCategoryNotFound,`,
+        "func errorConferenceDelete(op string, c_error C.TOX_ERR_CONFERENCE_DELETE) error {\n",
+        "        toxErrorCategories[err] = category\n",
+        "    tox.dispatchConferencePeerListChanged(conferenceNumber)\n",
+    }
+    for _, line := range expected {
+        if (!strings.Contains(output, line)) {
+            test.Fatalf("Failed to generate the c-toxcore 0.2 file. Missing %q.", line)
+        }
+    }
+}
+
+func TestSkip(test *testing.T) {
+    api := loadFixture(test)
+    api.skip([]string{"friend_status_message"})
+    if (len(api.Callbacks) != 1 || api.Callbacks[0].Name != "friend_connection_status") {
+        test.Fatalf("Failed to skip callback.")
+    }
+}
+
+func TestCamel(test *testing.T) {
+    cases := map[string]string {
+        "FRIEND_SEND_MESSAGE": "FriendSendMessage",
+        "SET_NEW_NOSPAM": "SetNewNoSpam",
+        "SENDQ": "SendQ",
+        "UDP": "UDP",
+    }
+    for name, expected := range cases {
+        if (camel(name) != expected) {
+            test.Fatalf("Failed to convert %s. Got %s.", name, camel(name))
+        }
+    }
+    if (lowerCamel("friend_number") != "friendNumber") {
+        test.Fatalf("Failed to convert friend_number.")
+    }
+}
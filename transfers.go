@@ -0,0 +1,444 @@
+/**
+ * File        : transfers.go
+ * Copyright   : Copyright (c) 2015-2017 Mirror Labs, Inc. All rights reserved.
+ * License     : GPLv3
+ * Maintainer  : Enzo Haussecker <enzo@mirror.co>, Dominic Williams <dominic@string.technology>
+ * Stability   : Experimental
+ * Portability : Non-portable (requires Tox core at commit dcf2aaa)
+ *
+ * This module provides managed file transfers on top of the raw file transfer
+ * API. Outgoing transfers read from an io.ReaderAt and incoming transfers write
+ * to an io.WriterAt. All chunk requests and chunk deliveries are handled from
+ * within Process, so clients never deal with positions or chunk sizes.
+ */
+
+package tox
+
+//#include "callbacks.h"
+import "C"
+import "context"
+import "io"
+import "sync"
+
+////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////// STRUCT TYPES /////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// This type represents a file transfer managed by a Tox instance. It can be
+// used to monitor progress, cancel the transfer, or wait for it to complete.
+type FileTransfer struct {
+
+    tox          *Tox
+    friendNumber uint32
+    fileNumber   uint32
+    size         uint64
+    reader       io.ReaderAt
+    writer       io.WriterAt
+    lock         sync.Mutex
+    transferred  uint64
+    done         chan struct{}
+    throw        error
+
+}
+
+// This type represents a file transfer offered by a friend. The transfer does
+// not start until it is accepted.
+type IncomingFileTransfer struct {
+
+    *FileTransfer
+    Kind     ToxFileKind
+    Filename []byte
+
+}
+
+// This type identifies a file transfer within a Tox instance.
+type transferKey struct {
+
+    friendNumber uint32
+    fileNumber   uint32
+
+}
+
+// This type holds the events of an outgoing file transfer that arrive while
+// the file is being offered, before the transfer is tracked. The events are
+// handled in order once the offer returns.
+type heldTransferEvents struct {
+
+    events    []func()
+    releasing bool
+
+}
+
+// This type represents a function that executes when a friend offers to send
+// a file. The function can be registered as a callback using
+// SetOnIncomingFile.
+type OnIncomingFile func(
+
+    tox *Tox, transfer *IncomingFileTransfer,
+
+)
+
+////////////////////////////////////////////////////////////////////////////////
+////////////////////////////// TRANSFER LIFECYCLE //////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Send a file to a friend. The data is read from the given reader on demand as
+// the core requests chunks, so the reader must remain valid until the transfer
+// completes. The returned handle can be used to track the transfer.
+func (tox *Tox) SendFile(friendNumber uint32, filename []byte, reader io.ReaderAt, size uint64) (transfer *FileTransfer, throw error) {
+    return tox.sendFile(friendNumber, nil, filename, reader, size)
+}
+
+// This function registers a function that executes when a friend offers to
+// send a file. While registered, it takes precedence over the callback set by
+// SetOnFileRecv.
+func (tox *Tox) SetOnIncomingFile(callback OnIncomingFile) {
+    tox.registerFileTransferCallbacks()
+    tox.transfersLock.Lock()
+    tox.onIncomingFile = callback
+    tox.transfersLock.Unlock()
+}
+
+// Accept an incoming file transfer. The received data is written to the given
+// writer at the offsets given by the sender.
+func (transfer *IncomingFileTransfer) Accept(writer io.WriterAt) (throw error) {
+    transfer.lock.Lock()
+    if (transfer.writer != nil || transfer.finished()) {
+        transfer.lock.Unlock()
+        return ToxErrFileTransferNotIncoming
+    }
+    transfer.writer = writer
+    transfer.lock.Unlock()
+    throw = transfer.tox.FileControl(transfer.friendNumber, transfer.fileNumber, ToxFileControlResume)
+    if throw != nil {
+        transfer.finish(throw)
+    }
+    return
+}
+
+// Reject an incoming file transfer.
+func (transfer *IncomingFileTransfer) Reject() (throw error) {
+    throw = transfer.tox.FileControl(transfer.friendNumber, transfer.fileNumber, ToxFileControlCancel)
+    transfer.finish(ToxErrFileTransferRejected)
+    return
+}
+
+// Cancel a file transfer. Any goroutine waiting on the transfer is released
+// with ToxErrFileTransferCancelled.
+func (transfer *FileTransfer) Cancel() (throw error) {
+    throw = transfer.tox.FileControl(transfer.friendNumber, transfer.fileNumber, ToxFileControlCancel)
+    transfer.finish(ToxErrFileTransferCancelled)
+    return
+}
+
+// Wait for a file transfer to complete. This returns nil if the transfer
+// completed successfully, the reason for failure if it did not, or the context
+// error if the context is done first.
+func (transfer *FileTransfer) Wait(ctx context.Context) (throw error) {
+    select {
+        case <-transfer.done:
+            transfer.lock.Lock()
+            throw = transfer.throw
+            transfer.lock.Unlock()
+        case <-ctx.Done():
+            throw = ctx.Err()
+    }
+    return
+}
+
+// Get the number of bytes transferred so far and the total size of the file.
+func (transfer *FileTransfer) Progress() (transferred uint64, size uint64) {
+    transfer.lock.Lock()
+    defer transfer.lock.Unlock()
+    return transfer.transferred, transfer.size
+}
+
+// Get the friend number associated with a file transfer.
+func (transfer *FileTransfer) FriendNumber() uint32 {
+    return transfer.friendNumber
+}
+
+// Get the file number associated with a file transfer.
+func (transfer *FileTransfer) FileNumber() uint32 {
+    return transfer.fileNumber
+}
+
+////////////////////////////////////////////////////////////////////////////////
+////////////////////////////////// UTILITIES ///////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Create a new file transfer handle.
+func newFileTransfer(tox *Tox, friendNumber uint32, fileNumber uint32, size uint64) *FileTransfer {
+    return &FileTransfer {
+        tox: tox.view,
+        friendNumber: friendNumber,
+        fileNumber: fileNumber,
+        size: size,
+        done: make(chan struct{}),
+    }
+}
+
+// Offer a file to a friend and track the resulting transfer. If the file
+// identifier is nil, then a random one is generated by the core.
+func (tox *Tox) sendFile(friendNumber uint32, fileId *ToxFileId, filename []byte, reader io.ReaderAt, size uint64) (transfer *FileTransfer, throw error) {
+    tox.registerFileTransferCallbacks()
+    tox.transfersLock.Lock()
+    tox.offering[friendNumber]++
+    tox.transfersLock.Unlock()
+    fileNumber, throw := tox.FileSend(friendNumber, ToxFileKindData, size, fileId, filename)
+    var released []transferKey
+    tox.transfersLock.Lock()
+    tox.offering[friendNumber]--
+    if (tox.offering[friendNumber] == 0) {
+        delete(tox.offering, friendNumber)
+        for key := range tox.held {
+            if (key.friendNumber == friendNumber) {
+                released = append(released, key)
+            }
+        }
+    }
+    if (throw == nil) {
+        transfer = newFileTransfer(tox, friendNumber, fileNumber, size)
+        transfer.reader = reader
+        tox.transfers[transferKey{friendNumber, fileNumber}] = transfer
+        released = append(released, transferKey{friendNumber, fileNumber})
+    }
+    tox.transfersLock.Unlock()
+    for _, key := range released {
+        tox.releaseTransferEvents(key)
+    }
+    return
+}
+
+// Hold an event of a file transfer that a friend may have been offered by a
+// call that has not returned yet. The core runs in parallel with the offer,
+// so the friend can accept the file before the transfer is tracked. This
+// returns false if the event can be handled right away.
+func (tox *Tox) holdTransferEvent(friendNumber uint32, fileNumber uint32, event func()) bool {
+    tox.transfersLock.Lock()
+    defer tox.transfersLock.Unlock()
+    var key = transferKey{friendNumber, fileNumber}
+    var held = tox.held[key]
+    if (held == nil) {
+        if (tox.offering[friendNumber] == 0 || tox.transfers[key] != nil) {
+            return false
+        }
+        held = &heldTransferEvents{}
+        tox.held[key] = held
+    }
+    held.events = append(held.events, event)
+    return true
+}
+
+// Handle the held events of a file transfer in order. Events that arrive
+// meanwhile are held until all earlier events are handled.
+func (tox *Tox) releaseTransferEvents(key transferKey) {
+    tox.transfersLock.Lock()
+    var held = tox.held[key]
+    if (held == nil || held.releasing) {
+        tox.transfersLock.Unlock()
+        return
+    }
+    held.releasing = true
+    for len(held.events) > 0 {
+        var events = held.events
+        held.events = nil
+        tox.transfersLock.Unlock()
+        for _, event := range events {
+            event()
+        }
+        tox.transfersLock.Lock()
+    }
+    delete(tox.held, key)
+    tox.transfersLock.Unlock()
+}
+
+// Check if a file transfer has completed. The caller must hold the transfer
+// lock.
+func (transfer *FileTransfer) finished() bool {
+    select {
+        case <-transfer.done:
+            return true
+        default:
+            return false
+    }
+}
+
+// Complete a file transfer with the given result and stop tracking it. Only
+// the first result is retained.
+func (transfer *FileTransfer) finish(throw error) {
+    transfer.lock.Lock()
+    if (!transfer.finished()) {
+        transfer.throw = throw
+        close(transfer.done)
+    }
+    transfer.lock.Unlock()
+    var tox = transfer.tox
+    var key = transferKey{transfer.friendNumber, transfer.fileNumber}
+    tox.transfersLock.Lock()
+    if (tox.transfers[key] == transfer) {
+        delete(tox.transfers, key)
+    }
+    tox.transfersLock.Unlock()
+}
+
+// Look up a managed file transfer.
+func (tox *Tox) lookupTransfer(friendNumber uint32, fileNumber uint32) *FileTransfer {
+    tox.transfersLock.Lock()
+    defer tox.transfersLock.Unlock()
+    return tox.transfers[transferKey{friendNumber, fileNumber}]
+}
+
+// Register the callbacks needed to drive managed file transfers.
+func (tox *Tox) registerFileTransferCallbacks() {
+    tox.transfersOnce.Do(func() {
+        tox.transfersLock.Lock()
+        tox.transfers = make(map[transferKey]*FileTransfer)
+        tox.offering = make(map[uint32]int)
+        tox.held = make(map[transferKey]*heldTransferEvents)
+        tox.transfersLock.Unlock()
+        tox.exec(nil, func() {
+            C.register_file_recv_control(tox.handle, C.uintptr_t(tox.id))
+            C.register_file_chunk_request(tox.handle, C.uintptr_t(tox.id))
+            C.register_file_recv(tox.handle, C.uintptr_t(tox.id))
+            C.register_file_recv_chunk(tox.handle, C.uintptr_t(tox.id))
+            C.register_friend_connection_status(tox.handle, C.uintptr_t(tox.id))
+        })
+    })
+}
+
+////////////////////////////////////////////////////////////////////////////////
+/////////////////////////////// EVENT PROCESSING ///////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Handle a file control command for a managed file transfer. This returns false
+// if the transfer is not managed.
+func (tox *Tox) handleFileRecvControl(friendNumber uint32, fileNumber uint32, control ToxFileControl) bool {
+    var transfer = tox.lookupTransfer(friendNumber, fileNumber)
+    if (transfer == nil) {
+        return false
+    }
+    if (control == ToxFileControlCancel) {
+        transfer.finish(ToxErrFileTransferCancelled)
+    }
+    return true
+}
+
+// Handle a chunk request for a managed outgoing file transfer. This returns
+// false if the transfer is not managed.
+func (tox *Tox) handleFileChunkRequest(friendNumber uint32, fileNumber uint32, position uint64, length int) bool {
+    var transfer = tox.lookupTransfer(friendNumber, fileNumber)
+    if (transfer == nil || transfer.reader == nil) {
+        return false
+    }
+    if (length == 0) {
+        transfer.lock.Lock()
+        transfer.transferred = transfer.size
+        transfer.lock.Unlock()
+        transfer.finish(nil)
+        return true
+    }
+    var data = make([]byte, length)
+    n, err := transfer.reader.ReadAt(data, int64(position))
+    if (n < length) {
+        if (err == nil) {
+            err = io.ErrUnexpectedEOF
+        }
+        tox.FileControl(friendNumber, fileNumber, ToxFileControlCancel)
+        transfer.finish(err)
+        return true
+    }
+    err = tox.FileSendChunk(friendNumber, fileNumber, position, data)
+    if err != nil {
+        tox.FileControl(friendNumber, fileNumber, ToxFileControlCancel)
+        transfer.finish(err)
+        return true
+    }
+    transfer.lock.Lock()
+    transfer.transferred = position + uint64(length)
+    transfer.lock.Unlock()
+    return true
+}
+
+// Handle a file offer from a friend. This returns false if no incoming file
+// callback is registered.
+func (tox *Tox) handleFileRecv(friendNumber uint32, fileNumber uint32, kind ToxFileKind, fileSize uint64, filename []byte) bool {
+    tox.transfersLock.Lock()
+    var callback = tox.onIncomingFile
+    var resumable = tox.resumable
+    if (callback == nil && resumable == nil) {
+        tox.transfersLock.Unlock()
+        return false
+    }
+    var transfer = &IncomingFileTransfer {
+        FileTransfer: newFileTransfer(tox, friendNumber, fileNumber, fileSize),
+        Kind: kind,
+        Filename: filename,
+    }
+    tox.transfers[transferKey{friendNumber, fileNumber}] = transfer.FileTransfer
+    tox.transfersLock.Unlock()
+    if (resumable != nil && resumable.handleFileRecv(transfer)) {
+        return true
+    }
+    if (callback == nil) {
+        transfer.finish(nil)
+        return false
+    }
+    callback(tox, transfer)
+    return true
+}
+
+// Handle a chunk of a managed incoming file transfer. This returns false if
+// the transfer is not managed.
+func (tox *Tox) handleFileRecvChunk(friendNumber uint32, fileNumber uint32, position uint64, data []byte) bool {
+    var transfer = tox.lookupTransfer(friendNumber, fileNumber)
+    if (transfer == nil) {
+        return false
+    }
+    transfer.lock.Lock()
+    var writer = transfer.writer
+    transfer.lock.Unlock()
+    if (writer == nil) {
+        return true
+    }
+    if (len(data) == 0) {
+        transfer.finish(nil)
+        return true
+    }
+    _, err := writer.WriteAt(data, int64(position))
+    if err != nil {
+        tox.FileControl(friendNumber, fileNumber, ToxFileControlCancel)
+        transfer.finish(err)
+        return true
+    }
+    transfer.lock.Lock()
+    transfer.transferred = position + uint64(len(data))
+    transfer.lock.Unlock()
+    return true
+}
+
+// Handle a change in the connection status of a friend. Transfers cannot
+// survive a lost connection, so all managed transfers with the friend fail.
+// Resumable transfers are offered again once the friend is back online.
+func (tox *Tox) handleTransfersConnectionStatus(friendNumber uint32, connectionStatus ToxConnectionStatus) {
+    tox.transfersLock.Lock()
+    var resumable = tox.resumable
+    tox.transfersLock.Unlock()
+    if (connectionStatus != ToxConnectionNone) {
+        if (resumable != nil) {
+            resumable.handleFriendOnline(friendNumber)
+        }
+        return
+    }
+    var broken []*FileTransfer
+    tox.transfersLock.Lock()
+    for key, transfer := range tox.transfers {
+        if (key.friendNumber == friendNumber) {
+            broken = append(broken, transfer)
+        }
+    }
+    tox.transfersLock.Unlock()
+    for _, transfer := range broken {
+        transfer.finish(ToxErrFileTransferBroken)
+    }
+}
@@ -24,18 +24,61 @@ import "unsafe"
 // a single network device is limited. Note that this is not just a per-process
 // limit, since the limiting factor is the number of usable ports on a device.
 type Tox struct {
+    *toxState
+}
 
-    handle                   *C.Tox
-    lock                     sync.Mutex
-    onSelfConnectionStatus   OnSelfConnectionStatus
-    onFriendRequest          OnFriendRequest
-    onFriendName             OnFriendName
-    onFriendStatus           OnFriendStatus
-    onFriendStatusMessage    OnFriendStatusMessage
-    onFriendConnectionStatus OnFriendConnectionStatus
-    onFriendMessage          OnFriendMessage
-    onFriendLosslessPacket   OnFriendLosslessPacket
-    userData                 unsafe.Pointer
+// This type holds the state of a Tox instance. The instance returned to the
+// client is the only one that carries a finalizer. The goroutines and helpers
+// of the package use a second instance that shares the state, so they do not
+// keep a leaked instance from being finalized.
+type toxState struct {
+
+    view                        *Tox
+    handle                      *C.Tox
+    id                          uintptr
+    logId                       uintptr
+    lock                        sync.Mutex
+    processLock                 sync.Mutex
+    pending                     []func()
+    owner                       *owner
+    onSelfConnectionStatus      OnSelfConnectionStatus
+    onFriendRequest             OnFriendRequest
+    onFriendName                OnFriendName
+    onFriendStatus              OnFriendStatus
+    onFriendStatusMessage       OnFriendStatusMessage
+    onFriendConnectionStatus    OnFriendConnectionStatus
+    onFriendTyping              OnFriendTyping
+    onFriendMessage             OnFriendMessage
+    onFriendReadReceipt         OnFriendReadReceipt
+    onFriendLossyPacket         OnFriendLossyPacket
+    onFriendLosslessPacket      OnFriendLosslessPacket
+    onFileRecvControl           OnFileRecvControl
+    onFileChunkRequest          OnFileChunkRequest
+    onFileRecv                  OnFileRecv
+    onFileRecvChunk             OnFileRecvChunk
+    onIncomingFile              OnIncomingFile
+    onConferenceInvite          OnConferenceInvite
+    onConferenceMessage         OnConferenceMessage
+    onConferenceTitle           OnConferenceTitle
+    onConferencePeerListChanged OnConferencePeerListChanged
+    transfers                   map[transferKey]*FileTransfer
+    transfersLock               sync.Mutex
+    transfersOnce               sync.Once
+    offering                    map[uint32]int
+    held                        map[transferKey]*heldTransferEvents
+    resumable                   *ResumableTransfers
+    deliveries                  *DeliveryTracker
+    deliveriesOnce              sync.Once
+    deliveriesLock              sync.Mutex
+    outbox                      *Outbox
+    outboxLock                  sync.Mutex
+    relays                      *TCPRelays
+    relaysLock                  sync.Mutex
+    run                         runState
+    streams                     []*eventStream
+    streamsLock                 sync.Mutex
+    subscriptions               [subscriptionCount]subscribers
+    userData                    unsafe.Pointer
 
 }
 
@@ -85,10 +128,44 @@ type ToxOptions struct {
     // disable it.
     TCPPort uint16
 
-    // The save data. This data is produced by serializing a Tox instance and
-    // supplied as a startup option to restore the instance to its active state.
+    // The type of the save data. If this is ToxSaveDataTypeNone and save data
+    // is given, then the save data is taken to be a full save, as produced by
+    // serializing a Tox instance.
+    SaveDataType ToxSaveDataType
+
+    // The save data. This data is either produced by serializing a Tox instance
+    // and supplied as a startup option to restore the instance to its active
+    // state, or a secret key from which a new instance with no friends is
+    // created.
     SaveData []byte
 
+    // The passphrase that the save data was encrypted with. If the save data
+    // is encrypted, then New decrypts it with this passphrase before passing it
+    // to the core. The passphrase is ignored if the save data is not
+    // encrypted.
+    Passphrase []byte
+
+    // The function that receives the log records of the core. If this is nil,
+    // then the records are written to the default logger of the log/slog
+    // package. The core only logs with c-toxcore 0.2, that is, when built with
+    // the toxcore02 build tag. At commit dcf2aaa, the logger only receives the
+    // records of the package itself, such as the destruction of a leaked
+    // instance.
+    Logger ToxLogger
+
+    // The lowest level of the log records to deliver. Records below this level
+    // are discarded before they reach the logger. The default is the info
+    // level, so trace and debug records are only delivered on request.
+    LogLevel ToxLogLevel
+
+    // Run the core on a dedicated goroutine locked to an OS thread. The
+    // goroutine iterates the core at the requested interval and executes every
+    // API call in the order in which the calls are submitted. Callbacks run on
+    // the same goroutine after each iteration, so they must not block on other
+    // goroutines that make API calls. In this mode, the instance runs without
+    // calls to Process or Run.
+    DedicatedThread bool
+
 }
 
 // This type represents a seed node. In order to facilitate quick connections
@@ -159,6 +236,15 @@ type OnFriendConnectionStatus func(
 
 )
 
+// This type represents a function that executes when a friend starts or stops
+// typing a message. The function can be registered as a callback using
+// SetOnFriendTyping.
+type OnFriendTyping func(
+
+    tox *Tox, friendNumber uint32, isTyping bool,
+
+)
+
 // This type represents a function that executes when receiving a chat message
 // from a friend. The function can be registered as a callback using
 // SetOnFriendMessage.
@@ -168,6 +254,25 @@ type OnFriendMessage func(
 
 )
 
+// This type represents a function that executes when a friend confirms that a
+// chat message was received. The message identifier is the one returned by
+// FriendSendMessage. The function can be registered as a callback using
+// SetOnFriendReadReceipt.
+type OnFriendReadReceipt func(
+
+    tox *Tox, friendNumber uint32, messageId uint32,
+
+)
+
+// This type represents a function that executes when receiving a custom lossy
+// packet from a friend. The function can be registered as a callback using
+// SetOnFriendLossyPacket.
+type OnFriendLossyPacket func(
+
+    tox *Tox, friendNumber uint32, data []byte,
+
+)
+
 // This type represents a function that executes when receiving a custom
 // loss-less packet from a friend. The function can be registered as a callback
 // using SetOnFriendLosslessPacket.
@@ -177,6 +282,92 @@ type OnFriendLosslessPacket func(
 
 )
 
+// This type represents a function that executes when a friend sends a file
+// control command, such as pausing, resuming, or cancelling a transfer. The
+// function can be registered as a callback using SetOnFileRecvControl.
+type OnFileRecvControl func(
+
+    tox *Tox, friendNumber uint32, fileNumber uint32, control ToxFileControl,
+
+)
+
+// This type represents a function that executes when the core requests the
+// next chunk of an outgoing file. The client should respond by calling
+// FileSendChunk with exactly the requested position and length. A length of
+// zero indicates that the transfer is complete. The function can be registered
+// as a callback using SetOnFileChunkRequest.
+type OnFileChunkRequest func(
+
+    tox *Tox, friendNumber uint32, fileNumber uint32, position uint64, length int,
+
+)
+
+// This type represents a function that executes when a friend offers to send
+// a file. The transfer is paused until the client accepts it by sending a
+// resume control with FileControl. The function can be registered as a
+// callback using SetOnFileRecv.
+type OnFileRecv func(
+
+    tox *Tox, friendNumber uint32, fileNumber uint32, kind ToxFileKind, fileSize uint64, filename []byte,
+
+)
+
+// This type represents a function that executes when receiving a chunk of an
+// incoming file. An empty chunk indicates that the transfer is complete. The
+// function can be registered as a callback using SetOnFileRecvChunk.
+type OnFileRecvChunk func(
+
+    tox *Tox, friendNumber uint32, fileNumber uint32, position uint64, data []byte,
+
+)
+
+// This type represents a function that executes when a friend invites the
+// client to a conference. The cookie can be passed to ConferenceJoin to join
+// the conference. The function can be registered as a callback using
+// SetOnConferenceInvite.
+type OnConferenceInvite func(
+
+    tox *Tox, friendNumber uint32, conferenceType ToxConferenceType, cookie []byte,
+
+)
+
+// This type represents a function that executes when receiving a chat message
+// from a conference peer. The function can be registered as a callback using
+// SetOnConferenceMessage.
+type OnConferenceMessage func(
+
+    tox *Tox, conferenceNumber uint32, peerNumber uint32, messageType ToxMessageType, message []byte,
+
+)
+
+// This type represents a function that executes when a conference peer changes
+// the conference title. The function can be registered as a callback using
+// SetOnConferenceTitle.
+type OnConferenceTitle func(
+
+    tox *Tox, conferenceNumber uint32, peerNumber uint32, title []byte,
+
+)
+
+// This type represents a function that executes when peers join or leave a
+// conference, or change their name. The function can be registered as a
+// callback using SetOnConferencePeerListChanged.
+type OnConferencePeerListChanged func(
+
+    tox *Tox, conferenceNumber uint32,
+
+)
+
+// This type represents a function that receives a log record from the core.
+// The function can be set as a startup option. It runs while the core is busy,
+// possibly before the instance is created, so it must not call into the
+// instance.
+type ToxLogger func(
+
+    level ToxLogLevel, file string, line uint32, function string, message string,
+
+)
+
 ////////////////////////////////////////////////////////////////////////////////
 /////////////////////////////// ENUMERATED TYPES ///////////////////////////////
 ////////////////////////////////////////////////////////////////////////////////
@@ -245,6 +436,73 @@ const (
 
 )
 
+// This type represents the type of save data supplied as a startup option.
+type ToxSaveDataType int
+
+// The set of possible save data types. The save data can either be absent, a
+// full save of a Tox instance, or only the secret key of a Tox instance.
+const (
+
+    ToxSaveDataTypeNone ToxSaveDataType = iota
+    ToxSaveDataTypeToxSave
+    ToxSaveDataTypeSecretKey
+
+)
+
+// This type represents the severity of a log record of the core.
+type ToxLogLevel int
+
+// The set of possible log levels, from the most verbose to the most severe.
+// Trace records are very frequent and mostly of interest to developers of the
+// core. The info level is the zero value.
+const (
+
+    ToxLogLevelTrace ToxLogLevel = iota - 2
+    ToxLogLevelDebug
+    ToxLogLevelInfo
+    ToxLogLevelWarning
+    ToxLogLevelError
+
+)
+
+// This type represents a Tox conference type.
+type ToxConferenceType int
+
+// The set of possible conference types. A conference can either carry text
+// messages only, or audio and video as well.
+const (
+
+    ToxConferenceTypeText ToxConferenceType = iota
+    ToxConferenceTypeAV
+
+)
+
+// This type represents a Tox file kind.
+type ToxFileKind uint32
+
+// The set of well-known file kinds. A file can either be plain data or the
+// avatar of the sender. Other values may be used by clients for custom kinds,
+// so values outside of this set are passed through unchanged.
+const (
+
+    ToxFileKindData ToxFileKind = iota
+    ToxFileKindAvatar
+
+)
+
+// This type represents a Tox file control command.
+type ToxFileControl int
+
+// The set of possible file control commands. A transfer can be resumed after
+// being paused or accepted, paused by either side, or cancelled altogether.
+const (
+
+    ToxFileControlResume ToxFileControl = iota
+    ToxFileControlPause
+    ToxFileControlCancel
+
+)
+
 ////////////////////////////////////////////////////////////////////////////////
 ///////////////////////////////// ARRAY TYPES //////////////////////////////////
 ////////////////////////////////////////////////////////////////////////////////
@@ -258,6 +516,9 @@ type ToxSecretKey [ToxSecretKeySize]byte
 // This type represents a Tox address.
 type ToxAddress [ToxAddressSize]byte
 
+// This type represents a Tox file identifier.
+type ToxFileId [ToxFileIdLength]byte
+
 ////////////////////////////////////////////////////////////////////////////////
 ////////////////////////////////// CONSTANTS ///////////////////////////////////
 ////////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,143 @@
+/**
+ * File        : typing.go
+ * Copyright   : Copyright (c) 2015-2017 Mirror Labs, Inc. All rights reserved.
+ * License     : GPLv3
+ * Maintainer  : Enzo Haussecker <enzo@mirror.co>, Dominic Williams <dominic@string.technology>
+ * Stability   : Experimental
+ * Portability : Non-portable (requires Tox core at commit dcf2aaa)
+ *
+ * This module provides a typing indicator that clears itself after a period of
+ * inactivity, so that a client never leaves its typing status stuck on.
+ */
+
+package tox
+
+import "errors"
+import "sync"
+import "time"
+
+////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////// STRUCT TYPES /////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// This type represents the typing status of the client for a friend. The
+// status is set whenever the user types, and cleared once the user has been
+// idle for the configured period. The status is sent to the core without
+// holding the indicator lock, since the handlers of the instance may use the
+// indicator, so the wanted status can change while it is being sent.
+type TypingIndicator struct {
+
+    setTyping    func(friendNumber uint32, isTyping bool) error
+    friendNumber uint32
+    idle         time.Duration
+    lock         sync.Mutex
+    timer        *time.Timer
+    generation   uint64
+    isTyping     bool
+    wanted       bool
+    updating     bool
+
+}
+
+// The idle period used by typing indicators when none is given.
+const DefaultTypingIdle = 5 * time.Second
+
+////////////////////////////////////////////////////////////////////////////////
+////////////////////////////// TYPING INDICATORS ///////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Create a typing indicator for a friend. The typing status is cleared once the
+// indicator has not been touched for the given idle period. If the idle period
+// is not positive, then DefaultTypingIdle is used.
+func (tox *Tox) NewTypingIndicator(friendNumber uint32, idle time.Duration) *TypingIndicator {
+    if (idle <= 0) {
+        idle = DefaultTypingIdle
+    }
+    return &TypingIndicator {
+        setTyping: tox.view.SetTyping,
+        friendNumber: friendNumber,
+        idle: idle,
+    }
+}
+
+// Signal that the user is typing. The typing status is sent to the friend if
+// it is not already set, and the idle period starts over.
+func (indicator *TypingIndicator) Touch() (throw error) {
+    indicator.lock.Lock()
+    defer indicator.lock.Unlock()
+    indicator.wanted = true
+    throw = indicator.update()
+    if throw != nil {
+        return
+    }
+    indicator.generation++
+    var generation = indicator.generation
+    if (indicator.timer != nil) {
+        indicator.timer.Stop()
+    }
+    indicator.timer = time.AfterFunc(indicator.idle, func() {
+        indicator.expire(generation)
+    })
+    return
+}
+
+// Clear the typing status immediately, for example because the message was
+// sent. If the typing status cannot be cleared, then the error is returned and
+// the indicator still counts as typing, so that it can be cleared again.
+func (indicator *TypingIndicator) Clear() (throw error) {
+    indicator.lock.Lock()
+    defer indicator.lock.Unlock()
+    indicator.generation++
+    if (indicator.timer != nil) {
+        indicator.timer.Stop()
+        indicator.timer = nil
+    }
+    indicator.wanted = false
+    return indicator.update()
+}
+
+// Clear the typing status when the idle period elapses. A timer that was
+// superseded by a later touch does nothing. If the typing status cannot be
+// cleared, then it is tried again after another idle period, unless the Tox
+// instance has been destroyed.
+func (indicator *TypingIndicator) expire(generation uint64) {
+    indicator.lock.Lock()
+    defer indicator.lock.Unlock()
+    if (generation != indicator.generation) {
+        return
+    }
+    indicator.wanted = false
+    if (!indicator.isTyping) {
+        return
+    }
+    indicator.timer = nil
+    var throw = indicator.update()
+    if (throw == nil || generation != indicator.generation) {
+        return
+    }
+    if (errors.Is(throw, ToxErrClosed)) {
+        return
+    }
+    indicator.timer = time.AfterFunc(indicator.idle, func() {
+        indicator.expire(generation)
+    })
+}
+
+// Send the wanted typing status to the core until the core has it. Only one
+// goroutine sends at a time, and it sends again if the wanted status changed
+// while the lock was released. The caller must hold the indicator lock.
+func (indicator *TypingIndicator) update() (throw error) {
+    for !indicator.updating && indicator.wanted != indicator.isTyping {
+        var wanted = indicator.wanted
+        indicator.updating = true
+        indicator.lock.Unlock()
+        throw = indicator.setTyping(indicator.friendNumber, wanted)
+        indicator.lock.Lock()
+        indicator.updating = false
+        if throw != nil {
+            return
+        }
+        indicator.isTyping = wanted
+    }
+    return
+}
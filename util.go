@@ -0,0 +1,59 @@
+/**
+ * File        : util.go
+ * Copyright   : Copyright (c) 2015-2017 Mirror Labs, Inc. All rights reserved.
+ * License     : GPLv3
+ * Maintainer  : Enzo Haussecker <enzo@mirror.co>, Dominic Williams <dominic@string.technology>
+ * Stability   : Experimental
+ * Portability : Non-portable (requires Tox core at commit dcf2aaa)
+ *
+ * This module holds the helpers that the file-backed stores of the package
+ * share, such as the outbox store and the transfer store.
+ */
+
+package tox
+
+import "encoding/hex"
+import "errors"
+import "io/ioutil"
+import "os"
+import "path/filepath"
+
+////////////////////////////////////////////////////////////////////////////////
+////////////////////////////////// UTILITIES ///////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Decode a hexadecimal string into a fixed-size byte array.
+func decodeHex(dst []byte, src string) (throw error) {
+    data, throw := hex.DecodeString(src)
+    if throw != nil {
+        return
+    }
+    if (len(data) != len(dst)) {
+        return errors.New("invalid length")
+    }
+    copy(dst, data)
+    return
+}
+
+// Replace the contents of a file atomically, so that a crash never leaves a
+// partially written file behind.
+func writeFileAtomic(path string, data []byte) (throw error) {
+    temp, throw := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+    if throw != nil {
+        return
+    }
+    _, throw = temp.Write(data)
+    if throw == nil {
+        throw = temp.Sync()
+    }
+    if err := temp.Close(); throw == nil {
+        throw = err
+    }
+    if throw == nil {
+        throw = os.Rename(temp.Name(), path)
+    }
+    if throw != nil {
+        os.Remove(temp.Name())
+    }
+    return
+}
@@ -0,0 +1,66 @@
+/**
+ * File        : version.go
+ * Copyright   : Copyright (c) 2015-2017 Mirror Labs, Inc. All rights reserved.
+ * License     : GPLv3
+ * Maintainer  : Enzo Haussecker <enzo@mirror.co>, Dominic Williams <dominic@string.technology>
+ * Stability   : Experimental
+ * Portability : Non-portable (requires Tox core at commit dcf2aaa or c-toxcore 0.2)
+ *
+ * This module reports the version of the Tox core. The bindings are built for
+ * either the API at commit dcf2aaa, which reports version 0.0.0, or the API of
+ * c-toxcore 0.2, which is selected with the toxcore02 build tag. The version of
+ * the library is detected at runtime, so that a mismatch between the header
+ * and the library is reported by New rather than causing memory corruption.
+ */
+
+package tox
+
+//#include "compat.h"
+import "C"
+import "fmt"
+
+////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////// STRUCT TYPES /////////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// This type represents a version of the Tox core.
+type ToxVersion struct {
+
+    Major uint32
+    Minor uint32
+    Patch uint32
+
+}
+
+////////////////////////////////////////////////////////////////////////////////
+//////////////////////////////// VERSION QUERIES ///////////////////////////////
+////////////////////////////////////////////////////////////////////////////////
+
+// Get the version of the Tox core library that the program is linked against.
+func Version() ToxVersion {
+    return ToxVersion {
+        Major: uint32(C.tox_version_major()),
+        Minor: uint32(C.tox_version_minor()),
+        Patch: uint32(C.tox_version_patch()),
+    }
+}
+
+// Get the version of the Tox core header that the bindings were built against.
+func HeaderVersion() ToxVersion {
+    return ToxVersion {
+        Major: uint32(C.TOX_VERSION_MAJOR),
+        Minor: uint32(C.TOX_VERSION_MINOR),
+        Patch: uint32(C.TOX_VERSION_PATCH),
+    }
+}
+
+// Check whether the library that the program is linked against implements the
+// API that the bindings were built for.
+func VersionIsCompatible() bool {
+    return bool(C.version_is_compatible())
+}
+
+// Get the version in the usual dotted form.
+func (version ToxVersion) String() string {
+    return fmt.Sprintf("%d.%d.%d", version.Major, version.Minor, version.Patch)
+}